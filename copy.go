@@ -0,0 +1,151 @@
+package scrud
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/cxr29/scrud/internal/table"
+)
+
+func copyFrom(ctx context.Context, xr faker, data interface{}) (int64, error) {
+	s := xr.Starter()
+	if s.DriverName() != "postgres" {
+		return insert(ctx, false, xr, data)
+	}
+
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, newError(ErrNilData, "scrud: copy from nil")
+		}
+		v = v.Elem()
+	}
+
+	t := v.Type()
+	if k := t.Kind(); k != reflect.Array && k != reflect.Slice {
+		return 0, newError(ErrInvalidData, "scrud: copy from need slice of struct")
+	}
+	cnt := v.Len()
+	if cnt == 0 {
+		return 0, newError(ErrEmptyData, "scrud: empty copy from")
+	}
+
+	ptr := false
+	t = t.Elem()
+	if t.Kind() == reflect.Ptr {
+		ptr = true
+		t = t.Elem()
+	}
+
+	x, err := table.TableOf(t)
+	if err != nil {
+		return 0, err
+	}
+	if v.Kind() != reflect.Slice && !ptr && needAddr(t, v, beforeInserterType) {
+		return 0, newError(ErrInvalidData, "scrud: copy from need pointer for BeforeInsert: "+x.Type.Name())
+	}
+
+	cols := make([]*table.Column, 0, len(x.Columns))
+	names := make([]string, 0, len(x.Columns))
+	for _, c := range x.Columns {
+		if c.IsManyRelation() {
+			continue
+		}
+		cols = append(cols, c)
+		names = append(names, s.FormatName(c.Name))
+	}
+
+	q := "COPY " + s.FormatName(x.Name) + " (" + strings.Join(names, ",") + ") FROM STDIN"
+
+	copyIn := func(tx *Tx) error {
+		rows := make([]reflect.Value, cnt)
+		for j := range rows {
+			w := v.Index(j)
+			if ptr {
+				if w.IsNil() {
+					return newError(ErrNilData, "scrud: copy from nil: "+x.Type.Name())
+				}
+				w = w.Elem()
+			}
+			if err := hook(ctx, tx, beforeInsert, w); err != nil {
+				return err
+			}
+			rows[j] = w
+		}
+
+		stmt, err := tx.PrepareContext(ctx, q)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		a := make([]interface{}, len(cols))
+		for _, w := range rows {
+			for k, c := range cols {
+				if a[k], err = c.GetValue(w); err != nil {
+					return err
+				}
+			}
+			if _, err := stmt.ExecContext(ctx, a...); err != nil {
+				return err
+			}
+		}
+
+		if _, err = stmt.ExecContext(ctx); err != nil { // flush
+			return err
+		}
+		if err = stmt.Close(); err != nil { // end the copy so the after hooks can query
+			return err
+		}
+
+		for _, w := range rows {
+			if err := hook(ctx, tx, afterInsert, w); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch i := xr.(type) {
+	case *Tx:
+		err = copyIn(i)
+	case *DB:
+		var tx *Tx
+		if tx, err = i.BeginTx(ctx, nil); err == nil {
+			if err = copyIn(tx); err != nil {
+				tx.Rollback()
+			} else {
+				err = tx.Commit()
+			}
+		}
+	default:
+		err = newError(ErrInvalidData, "scrud: copy from need DB or Tx")
+	}
+	if err != nil {
+		return 0, mapError(err)
+	}
+
+	return int64(cnt), nil
+}
+
+// bulk load slice of struct, same columns and hooks as Load,
+// postgres stream rows by COPY FROM STDIN and need driver CopyIn support such as lib/pq,
+// the insert hooks run in the transaction, before ones ahead of the copy and after ones once it is done,
+// others fall back to Load
+func (db *DB) CopyFrom(data interface{}) (int64, error) {
+	return copyFrom(context.Background(), db, data)
+}
+
+// same as CopyFrom with context
+func (db *DB) CopyFromContext(ctx context.Context, data interface{}) (int64, error) {
+	return copyFrom(ctx, db, data)
+}
+
+func (tx *Tx) CopyFrom(data interface{}) (int64, error) {
+	return copyFrom(context.Background(), tx, data)
+}
+
+func (tx *Tx) CopyFromContext(ctx context.Context, data interface{}) (int64, error) {
+	return copyFrom(ctx, tx, data)
+}
@@ -0,0 +1,162 @@
+package scrud
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/cxr29/scrud/internal/table"
+)
+
+// use errors.Is to check the cause of the error
+var (
+	ErrNilData             = errors.New("scrud: nil data")
+	ErrInvalidData         = table.ErrInvalidData
+	ErrEmptyData           = errors.New("scrud: empty data")
+	ErrNoPrimaryKey        = errors.New("scrud: no primary key")
+	ErrNoColumns           = errors.New("scrud: no columns")
+	ErrNotRelation         = errors.New("scrud: not relation")
+	ErrUnsupportedDriver   = errors.New("scrud: unsupported driver")
+	ErrUniqueViolation     = errors.New("scrud: unique violation")
+	ErrForeignKeyViolation = errors.New("scrud: foreign key violation")
+	// update a struct with version column but the row is changed or deleted by others
+	ErrStaleObject = errors.New("scrud: stale object")
+	// the table or column of the model not found in the database by Verify
+	ErrSchemaMismatch = errors.New("scrud: schema mismatch")
+)
+
+// struct tag or hook method not correct, use errors.As to check
+type DefinitionError = table.DefinitionError
+
+// use errors.As to check
+type ColumnNotFoundError struct {
+	Op     string // such as select, update
+	Table  string // struct name
+	Column string // field or column name
+}
+
+func (e *ColumnNotFoundError) Error() string {
+	return "scrud: " + e.Op + " column not found: " + e.Table + "/" + e.Column
+}
+
+// the existing columns differ from the models by Migrate on sqlite, use errors.As to check, also is ErrSchemaMismatch
+type DriftError struct {
+	Columns []ColumnDrift
+}
+
+type ColumnDrift struct {
+	Table  string
+	Column string
+	Want   string // type and NULL or NOT NULL of the model
+	Have   string // of the database
+}
+
+func (e *DriftError) Error() string {
+	s := "scrud: migrate column drift:"
+	for k, v := range e.Columns {
+		if k > 0 {
+			s += ";"
+		}
+		s += " " + v.Table + "/" + v.Column + " want " + v.Want + " have " + v.Have
+	}
+	return s
+}
+
+func (e *DriftError) Is(target error) bool {
+	return target == ErrSchemaMismatch
+}
+
+// message with the cause
+type causeError struct {
+	msg   string
+	cause error
+}
+
+func (e *causeError) Error() string {
+	return e.msg
+}
+
+func (e *causeError) Unwrap() error {
+	return e.cause
+}
+
+func newError(cause error, msg string) error {
+	return &causeError{msg, cause}
+}
+
+// driver error keep the message and the original error, also is ErrUniqueViolation or ErrForeignKeyViolation
+type driverError struct {
+	err  error
+	kind error
+}
+
+func (e *driverError) Error() string {
+	return e.err.Error()
+}
+
+func (e *driverError) Unwrap() error {
+	return e.err
+}
+
+func (e *driverError) Is(target error) bool {
+	return target == e.kind
+}
+
+// map constraint violation of the drivers without import them,
+// mysql Number, postgres SQLSTATE Code, sqlite extended code by modernc Code() or mattn ExtendedCode
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*driverError); ok {
+		return err
+	}
+
+	var kind error
+	if i, ok := err.(interface {
+		SQLState() string
+	}); ok {
+		kind = sqlState(i.SQLState())
+	} else if i, ok := err.(interface {
+		Code() int
+	}); ok { // modernc sqlite
+		kind = sqliteCode(int64(i.Code()))
+	} else if v := reflect.Indirect(reflect.ValueOf(err)); v.Kind() == reflect.Struct {
+		if f := v.FieldByName("Number"); f.IsValid() && f.Kind() == reflect.Uint16 { // mysql
+			switch f.Uint() {
+			case 1062, 1586:
+				kind = ErrUniqueViolation
+			case 1216, 1217, 1451, 1452:
+				kind = ErrForeignKeyViolation
+			}
+		} else if f := v.FieldByName("ExtendedCode"); f.IsValid() && f.Kind() == reflect.Int { // mattn sqlite
+			kind = sqliteCode(f.Int())
+		} else if f := v.FieldByName("Code"); f.IsValid() && f.Kind() == reflect.String { // postgres
+			kind = sqlState(f.String())
+		}
+	}
+
+	if kind == nil {
+		return err
+	}
+	return &driverError{err, kind}
+}
+
+func sqliteCode(n int64) error {
+	switch n {
+	case 1555, 2067: // primary key, unique
+		return ErrUniqueViolation
+	case 787:
+		return ErrForeignKeyViolation
+	}
+	return nil
+}
+
+func sqlState(s string) error {
+	switch s {
+	case "23505":
+		return ErrUniqueViolation
+	case "23503":
+		return ErrForeignKeyViolation
+	}
+	return nil
+}
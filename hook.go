@@ -0,0 +1,137 @@
+package scrud
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	. "github.com/cxr29/scrud/query"
+)
+
+// DB or Tx, pass to the hooks to run queries in the same transaction
+type Executor interface {
+	Starter() Starter
+	Insert(interface{}) (int64, error)
+	InsertContext(context.Context, interface{}) (int64, error)
+	Load(interface{}) (int64, error)
+	LoadContext(context.Context, interface{}) (int64, error)
+	Upsert(interface{}, ...string) (int64, error)
+	UpsertContext(context.Context, interface{}, ...string) (int64, error)
+	Select(interface{}, ...string) error
+	SelectContext(context.Context, interface{}, ...string) error
+	SelectRelation(string, interface{}, ...string) error
+	SelectRelationContext(context.Context, string, interface{}, ...string) error
+	SelectWith(interface{}, ...string) error
+	SelectWithContext(context.Context, interface{}, ...string) error
+	Preload(interface{}, ...string) error
+	PreloadContext(context.Context, interface{}, ...string) error
+	Update(interface{}, ...string) error
+	UpdateContext(context.Context, interface{}, ...string) error
+	Delete(interface{}) error
+	DeleteContext(context.Context, interface{}) error
+	HardDelete(interface{}) error
+	HardDeleteContext(context.Context, interface{}) error
+	Fetch(Expression) *Rows
+	FetchContext(context.Context, Expression) *Rows
+	Run(Expression) (sql.Result, error)
+	RunContext(context.Context, Expression) (sql.Result, error)
+	ManyToMany(string, interface{}) *ManyToMany
+	Snapshot() *Snapshot
+}
+
+// hooks implemented by the struct or *struct, error of before hook abort the operation,
+// error of after hook return but the operation is done unless in a rollback transaction,
+// before hooks need a pointer to keep the changes, Upsert call no hooks
+
+type BeforeInserter interface {
+	BeforeInsert(context.Context, Executor) error
+}
+
+type AfterInserter interface {
+	AfterInsert(context.Context, Executor) error
+}
+
+type BeforeUpdater interface {
+	BeforeUpdate(context.Context, Executor) error
+}
+
+type AfterUpdater interface {
+	AfterUpdate(context.Context, Executor) error
+}
+
+type BeforeDeleter interface {
+	BeforeDelete(context.Context, Executor) error
+}
+
+type AfterDeleter interface {
+	AfterDelete(context.Context, Executor) error
+}
+
+// called after select by primary key, Rows.One and Rows.All once the rows are closed
+type AfterSelecter interface {
+	AfterSelect(context.Context, Executor) error
+}
+
+const (
+	beforeInsert = iota + 1
+	afterInsert
+	beforeUpdate
+	afterUpdate
+	beforeDelete
+	afterDelete
+	afterSelect
+)
+
+var (
+	beforeInserterType = reflect.TypeOf((*BeforeInserter)(nil)).Elem()
+	beforeUpdaterType  = reflect.TypeOf((*BeforeUpdater)(nil)).Elem()
+)
+
+// changes of the before hook h would be lost on a copy
+func needAddr(t reflect.Type, v reflect.Value, h reflect.Type) bool {
+	return !v.CanAddr() && reflect.PtrTo(t).Implements(h)
+}
+
+// call the hook of struct value, not addressable call on a copy
+func hook(ctx context.Context, xr faker, h int, v reflect.Value) error {
+	var i interface{}
+	if v.CanAddr() {
+		i = v.Addr().Interface()
+	} else {
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		i = p.Interface()
+	}
+
+	switch h {
+	case beforeInsert:
+		if j, ok := i.(BeforeInserter); ok {
+			return j.BeforeInsert(ctx, xr)
+		}
+	case afterInsert:
+		if j, ok := i.(AfterInserter); ok {
+			return j.AfterInsert(ctx, xr)
+		}
+	case beforeUpdate:
+		if j, ok := i.(BeforeUpdater); ok {
+			return j.BeforeUpdate(ctx, xr)
+		}
+	case afterUpdate:
+		if j, ok := i.(AfterUpdater); ok {
+			return j.AfterUpdate(ctx, xr)
+		}
+	case beforeDelete:
+		if j, ok := i.(BeforeDeleter); ok {
+			return j.BeforeDelete(ctx, xr)
+		}
+	case afterDelete:
+		if j, ok := i.(AfterDeleter); ok {
+			return j.AfterDelete(ctx, xr)
+		}
+	case afterSelect:
+		if j, ok := i.(AfterSelecter); ok {
+			return j.AfterSelect(ctx, xr)
+		}
+	}
+	return nil
+}
@@ -11,8 +11,8 @@ import (
 	"encoding/gob"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -38,6 +38,35 @@ type Througher interface {
 	ThroughTable(string) (interface{}, string, string)
 }
 
+// struct tag or hook method not correct
+type DefinitionError struct {
+	Field  string // struct name or struct.field
+	Reason string
+}
+
+func (e *DefinitionError) Error() string {
+	return "table: " + e.Reason + ": " + e.Field
+}
+
+// value not match the column, the same one as scrud.ErrInvalidData
+var ErrInvalidData = errors.New("scrud: invalid data")
+
+type valueError struct {
+	msg string
+}
+
+func (e *valueError) Error() string {
+	return e.msg
+}
+
+func (e *valueError) Unwrap() error {
+	return ErrInvalidData
+}
+
+func newValueError(msg string) error {
+	return &valueError{msg}
+}
+
 type Table struct {
 	Type          reflect.Type
 	Value         reflect.Value
@@ -45,10 +74,13 @@ type Table struct {
 	Columns       []*Column
 	FieldMap      map[string]*Column
 	ColumnMap     map[string]*Column // no many relation columns
-	PrimaryKey    *Column
+	PrimaryKey    *Column            // nil if composite primary key
+	PrimaryKeys   []*Column          // include the single primary key
 	AutoIncrement *Column
 	AutoNowAdd    *Column
 	AutoNow       *Column
+	SoftDelete    *Column
+	Version       *Column
 }
 
 // field name then column name
@@ -76,28 +108,36 @@ func (t *Table) FindColumn(s string) *Column {
 type Column struct {
 	Table              *Table
 	Type               reflect.Type
-	Index              int
+	Index              int   // ordinal in the table columns
+	Path               []int // field index sequence, see reflect.Value.FieldByIndex
 	Field              string
-	Name               string // many_to_many is table name
+	Name               string // many_to_many is table name, one_to_many is the first of NameLeft
 	Relation           int
 	RelationType       reflect.Type
 	RelationTable      *Table
+	RelationKey        *Column // one relation only, the primary key of the relation struct refer to
 	Valuer, Scanner    bool
 	Getter, Setter     int
 	GetType, SetType   reflect.Type
 	GetError, SetError bool
 	GetPointer         bool
 	Encoding           string // json or gob
+	Definition         Definition
+	// one_to_many the columns of the relation struct, many_to_many the columns refer to the struct,
+	// one per primary key
+	NameLeft []string
 	// many_to_many only
-	NameLeft, NameRight       string
+	NameRight                 []string // the columns refer to the relation struct, one per primary key
 	ThroughTable              *Table
-	ThroughLeft, ThroughRight *Column
+	ThroughLeft, ThroughRight *Column   // the first column if composite primary key, see FieldColumns
+	prefix                    string    // column name prefix of embedded struct
+	more                      []*Column // the other columns of one relation to composite primary key
 }
 
 func (c *Column) init(x map[reflect.Type]*Table) error {
 	if c.Relation != 0 {
 		if c.HasEncoding() || c.HasGetter() || c.HasSetter() {
-			return errors.New("table: relation field not allow encoding, getter and setter: " + c.FullName())
+			return &DefinitionError{Field: c.FullName(), Reason: "relation field not allow encoding, getter and setter"}
 		}
 
 		var err error
@@ -109,16 +149,16 @@ func (c *Column) init(x map[reflect.Type]*Table) error {
 
 	switch c.Relation {
 	case OneToMany, ManyToMany:
-		if c.Table.PrimaryKey == nil {
-			return errors.New("table: struct need primary_key: " + c.FullName())
+		if len(c.Table.PrimaryKeys) == 0 {
+			return &DefinitionError{Field: c.FullName(), Reason: "struct need primary_key"}
 		}
 	}
 
 	rt := c.RelationTable
 	switch c.Relation {
 	case OneToOne, ManyToOne, ManyToMany:
-		if rt.PrimaryKey == nil {
-			return errors.New("table: relation struct need primary_key: " + c.FullName())
+		if len(rt.PrimaryKeys) == 0 {
+			return &DefinitionError{Field: c.FullName(), Reason: "relation struct need primary_key"}
 		}
 	}
 
@@ -126,46 +166,52 @@ func (c *Column) init(x map[reflect.Type]*Table) error {
 	througher, _ := c.Table.Value.Interface().(Througher)
 
 	if !isManyRelation(c.Relation) {
-		if c.Name == "" {
+		if c.Relation != 0 {
+			names, err := c.keyNames(columner, rt, c.Name)
+			if err != nil {
+				return err
+			}
+			c.Name, c.RelationKey = names[0], rt.PrimaryKeys[0]
+			for k, pk := range rt.PrimaryKeys[1:] {
+				o := *c
+				o.Name, o.RelationKey, o.more = names[k+1], pk, nil
+				c.more = append(c.more, &o)
+			}
+		} else if c.Name == "" {
 			if columner != nil {
-				if c.Relation != 0 {
-					c.Name = columner.ColumnName(c.Field,
-						rt.Type.Name(), rt.PrimaryKey.Field, rt.Name, rt.PrimaryKey.Name)
-				} else {
-					c.Name = columner.ColumnName(c.Field)
-				}
-			} else if c.Relation != 0 {
-				c.Name = format.ColumnName(c.Field, c.Table.Type.Name(), c.Table.Name,
-					rt.Type.Name(), rt.PrimaryKey.Field, rt.Name, rt.PrimaryKey.Name)
+				c.Name = columner.ColumnName(c.Field)
 			} else {
 				c.Name = format.ColumnName(c.Field, c.Table.Type.Name(), c.Table.Name)
 			}
 		}
 
-		if _, ok := c.Table.ColumnMap[c.Name]; ok {
-			return errors.New("table: column name repeat: " + c.FullName())
-		} else {
-			c.Table.ColumnMap[c.Name] = c
-		}
-	} else if c.Relation == OneToMany {
-		if c.Name == "" {
-			if columner != nil {
-				c.Name = columner.ColumnName(c.Field,
-					c.Table.Type.Name(), c.Table.PrimaryKey.Field, c.Table.Name, c.Table.PrimaryKey.Name)
+		for _, o := range append([]*Column{c}, c.more...) {
+			o.Name = c.prefix + o.Name
+
+			if _, ok := c.Table.ColumnMap[o.Name]; ok {
+				return &DefinitionError{Field: c.FullName(), Reason: "column name repeat"}
 			} else {
-				c.Name = format.ColumnName(c.Field, c.Table.Type.Name(), c.Table.Name,
-					c.Table.Type.Name(), c.Table.PrimaryKey.Field, c.Table.Name, c.Table.PrimaryKey.Name,
-				)
+				c.Table.ColumnMap[o.Name] = o
 			}
 		}
+	} else if c.Relation == OneToMany {
+		var err error
+		if c.NameLeft, err = c.keyNames(columner, c.Table, c.Name); err != nil {
+			return err
+		}
+		c.Name = c.NameLeft[0]
 	} else if c.Relation == ManyToMany {
+		var left, right string
 		if c.Name != "" {
-			if i := strings.Index(c.Name, "|"); i != -1 {
-				c.NameLeft = c.Name[i+1:]
-				c.Name = c.Name[:i]
-				if i := strings.Index(c.NameLeft, "|"); i != -1 {
-					c.NameRight = c.NameLeft[i+1:]
-					c.NameLeft = c.NameLeft[:i]
+			if a := strings.Split(c.Name, "|"); len(a) > 1 {
+				c.Name = a[0]
+				switch n := len(c.Table.PrimaryKeys); len(a) - 1 {
+				case n:
+					left = strings.Join(a[1:], "|")
+				case n + len(rt.PrimaryKeys):
+					left, right = strings.Join(a[1:n+1], "|"), strings.Join(a[n+1:], "|")
+				default:
+					return &DefinitionError{Field: c.FullName(), Reason: "many_to_many column names not match primary_key"}
 				}
 			}
 		}
@@ -176,24 +222,12 @@ func (c *Column) init(x map[reflect.Type]*Table) error {
 				rt.Type.Name(), rt.Name)
 		}
 
-		if c.NameLeft == "" {
-			if columner != nil {
-				c.NameLeft = columner.ColumnName(c.Field,
-					c.Table.Type.Name(), c.Table.PrimaryKey.Field, c.Table.Name, c.Table.PrimaryKey.Name)
-			} else {
-				c.NameLeft = format.ColumnName(c.Field, c.Table.Type.Name(), c.Table.Name,
-					c.Table.Type.Name(), c.Table.PrimaryKey.Field, c.Table.Name, c.Table.PrimaryKey.Name)
-			}
+		var err error
+		if c.NameLeft, err = c.keyNames(columner, c.Table, left); err != nil {
+			return err
 		}
-
-		if c.NameRight == "" {
-			if columner != nil {
-				c.NameRight = columner.ColumnName(c.Field,
-					rt.Type.Name(), rt.PrimaryKey.Field, rt.Name, rt.PrimaryKey.Name)
-			} else {
-				c.NameRight = format.ColumnName(c.Field, c.Table.Type.Name(), c.Table.Name,
-					rt.Type.Name(), rt.PrimaryKey.Field, rt.Name, rt.PrimaryKey.Name)
-			}
+		if c.NameRight, err = c.keyNames(columner, rt, right); err != nil {
+			return err
 		}
 
 		if througher != nil {
@@ -205,16 +239,16 @@ func (c *Column) init(x map[reflect.Type]*Table) error {
 
 				lc := tt.FindField(lf)
 				if lc == nil {
-					return fmt.Errorf("table: through field not found: %s/%s", tt.Type.Name(), lf)
+					return &DefinitionError{Field: tt.Type.Name() + "." + lf, Reason: "through field not found"}
 				} else if lc.Relation != ManyToOne || lc.RelationTable.Type != c.Table.Type {
-					return errors.New("table: through not correct: " + lc.FullName())
+					return &DefinitionError{Field: lc.FullName(), Reason: "through not correct"}
 				}
 
 				rc := tt.FindField(rf)
 				if rc == nil {
-					return fmt.Errorf("table: through field not found: %s/%s", tt.Type.Name(), rf)
+					return &DefinitionError{Field: tt.Type.Name() + "." + rf, Reason: "through field not found"}
 				} else if rc.Relation != ManyToOne || rc.RelationTable.Type != c.RelationTable.Type {
-					return errors.New("table: through not correct: " + rc.FullName())
+					return &DefinitionError{Field: rc.FullName(), Reason: "through not correct"}
 				}
 
 				c.ThroughTable = tt
@@ -223,17 +257,67 @@ func (c *Column) init(x map[reflect.Type]*Table) error {
 			}
 		}
 
-		if (c.ThroughTable == nil && c.NameLeft == c.NameRight) ||
-			(c.ThroughTable != nil && c.ThroughLeft.Name == c.ThroughRight.Name) {
-			return errors.New("table: many_to_many column name repeat: " + c.FullName())
+		if c.ThroughTable == nil {
+			seen := make(map[string]struct{})
+			for _, name := range append(append([]string{}, c.NameLeft...), c.NameRight...) {
+				if _, ok := seen[name]; ok {
+					return &DefinitionError{Field: c.FullName(), Reason: "many_to_many column name repeat"}
+				}
+				seen[name] = struct{}{}
+			}
+		} else if c.ThroughLeft.Field == c.ThroughRight.Field {
+			return &DefinitionError{Field: c.FullName(), Reason: "many_to_many column name repeat"}
 		}
 	}
 
 	return nil
 }
 
+// the column names refer to the primary keys of the table, s is the names separated by "|" if not empty
+func (c *Column) keyNames(columner Columner, x *Table, s string) ([]string, error) {
+	if s != "" {
+		a := strings.Split(s, "|")
+		if len(a) != len(x.PrimaryKeys) {
+			return nil, &DefinitionError{Field: c.FullName(), Reason: "column names not match primary_key of " + x.Type.Name()}
+		}
+		return a, nil
+	}
+
+	a := make([]string, len(x.PrimaryKeys))
+	for k, pk := range x.PrimaryKeys {
+		if columner != nil {
+			a[k] = columner.ColumnName(c.Field, x.Type.Name(), pk.Field, x.Name, pk.Name)
+		} else {
+			a[k] = format.ColumnName(c.Field, c.Table.Type.Name(), c.Table.Name,
+				x.Type.Name(), pk.Field, x.Name, pk.Name)
+		}
+	}
+	return a, nil
+}
+
+// the columns of the field, more than one if one relation to composite primary key
+func (c *Column) FieldColumns() []*Column {
+	f := c.Table.FieldMap[c.Field]
+	return append([]*Column{f}, f.more...)
+}
+
+// insert the other columns of one relation to composite primary key after the first
+func expandColumns(a []*Column) []*Column {
+	b := make([]*Column, 0, len(a))
+	for _, c := range a {
+		b = append(b, c)
+		b = append(b, c.more...)
+	}
+	return b
+}
+
 func (c *Column) PrimaryKey() bool {
-	return c.Table.PrimaryKey != nil && c.Table.PrimaryKey.Index == c.Index
+	for _, pk := range c.Table.PrimaryKeys {
+		if pk.Index == c.Index {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Column) AutoIncrement() bool {
@@ -248,6 +332,14 @@ func (c *Column) AutoNow() bool {
 	return c.Table.AutoNow != nil && c.Table.AutoNow.Index == c.Index
 }
 
+func (c *Column) SoftDelete() bool {
+	return c.Table.SoftDelete != nil && c.Table.SoftDelete.Index == c.Index
+}
+
+func (c *Column) Version() bool {
+	return c.Table.Version != nil && c.Table.Version.Index == c.Index
+}
+
 func (c *Column) IsOneRelation() bool {
 	return isOneRelation(c.Relation)
 }
@@ -272,25 +364,30 @@ func (c *Column) FullName() string {
 	return c.Table.Type.Name() + "." + c.Field
 }
 
+// field of the struct value, support embedded struct
+func (c *Column) FieldValue(v reflect.Value) reflect.Value {
+	return v.FieldByIndex(c.Path)
+}
+
 // many relation get the field value
 func (c *Column) GetValue(v reflect.Value) (interface{}, error) {
 	if t := v.Type(); t != c.Table.Type {
-		return nil, errors.New("table: get value type mismatching: " + c.FullName())
+		return nil, newValueError("table: get value type mismatching: " + c.FullName())
 	}
 
 	if isOneRelation(c.Relation) {
-		v = v.Field(c.Index)
+		v = c.FieldValue(v)
 		if v.Kind() == reflect.Ptr {
 			if v.IsNil() {
 				return nil, nil
 			}
 			v = v.Elem()
 		}
-		return c.RelationTable.PrimaryKey.GetValue(v)
+		return c.RelationKey.GetValue(v)
 	}
 
 	if c.HasEncoding() {
-		v = v.Field(c.Index)
+		v = c.FieldValue(v)
 		switch c.Encoding {
 		case "json":
 			return json.Marshal(v.Interface())
@@ -303,7 +400,7 @@ func (c *Column) GetValue(v reflect.Value) (interface{}, error) {
 		var m reflect.Value
 		if c.GetPointer {
 			if !v.CanAddr() {
-				return nil, errors.New("table: getter need pointer receiver: " + c.FullName())
+				return nil, newValueError("table: getter need pointer receiver: " + c.FullName())
 			}
 			m = v.Addr().Method(c.Getter)
 		} else {
@@ -316,15 +413,15 @@ func (c *Column) GetValue(v reflect.Value) (interface{}, error) {
 		}
 	}
 
-	return v.Field(c.Index).Interface(), nil
+	return c.FieldValue(v).Interface(), nil
 }
 
 func (c *Column) Set(v reflect.Value, i interface{}) error {
 	if t := v.Type(); t != c.Table.Type {
-		return errors.New("table: set type mismatching: " + c.FullName())
+		return newValueError("table: set type mismatching: " + c.FullName())
 	}
 
-	v = v.Field(c.Index)
+	v = c.FieldValue(v)
 
 	if v.CanSet() && (func() (ok bool) {
 		defer func() {
@@ -338,12 +435,12 @@ func (c *Column) Set(v reflect.Value, i interface{}) error {
 		return nil
 	}
 
-	return errors.New("table: set failed: " + c.FullName())
+	return newValueError("table: set failed: " + c.FullName())
 }
 
 func (c *Column) Decode(p []byte) (interface{}, error) {
 	if !c.HasEncoding() {
-		return nil, errors.New("table: decode need encoding: " + c.FullName())
+		return nil, newValueError("table: decode need encoding: " + c.FullName())
 	}
 
 	ptr, t := false, c.Type
@@ -374,11 +471,11 @@ func (c *Column) Decode(p []byte) (interface{}, error) {
 // many relation set the field value
 func (c *Column) SetValue(v reflect.Value, i interface{}) error {
 	if t := v.Type(); t != c.Table.Type {
-		return errors.New("table: set value type mismatching: " + c.FullName())
+		return newValueError("table: set value type mismatching: " + c.FullName())
 	}
 
 	if isOneRelation(c.Relation) {
-		v = v.Field(c.Index)
+		v = c.FieldValue(v)
 		if v.Kind() == reflect.Ptr {
 			if v.IsNil() {
 				if !v.CanSet() {
@@ -388,7 +485,7 @@ func (c *Column) SetValue(v reflect.Value, i interface{}) error {
 			}
 			v = v.Elem()
 		}
-		return c.RelationTable.PrimaryKey.SetValue(v, i)
+		return c.RelationKey.SetValue(v, i)
 	}
 
 	if c.HasEncoding() {
@@ -407,7 +504,7 @@ func (c *Column) SetValue(v reflect.Value, i interface{}) error {
 		return c.Set(v, i)
 	} else if c.HasSetter() {
 		if !v.CanAddr() {
-			return errors.New("table: setter need pointer receiver: " + c.FullName())
+			return newValueError("table: setter need pointer receiver: " + c.FullName())
 		}
 		in := make([]reflect.Value, 1)
 		if c.SetType == typeInterface {
@@ -468,7 +565,7 @@ func (c *Column) SetValue(v reflect.Value, i interface{}) error {
 		return nil
 	}
 
-	v = v.Field(c.Index)
+	v = c.FieldValue(v)
 	if v.CanSet() && (func() (ok bool) {
 		defer func() {
 			if recover() != nil {
@@ -518,7 +615,7 @@ func (c *Column) SetValue(v reflect.Value, i interface{}) error {
 	}
 
 failed:
-	return errors.New("table: set value failed: " + c.FullName())
+	return newValueError("table: set value failed: " + c.FullName())
 }
 
 // panic if v is not table's type
@@ -532,23 +629,15 @@ func (c *Column) Scan(v reflect.Value) interface{} {
 	} else if c.HasSetter() {
 		return reflect.New(c.SetType).Interface()
 	} else {
-		fv := v.Field(c.Index)
-		if c.IsOneRelation() {
-			rt := c.RelationTable
-			for {
-				if fv.Kind() == reflect.Ptr {
-					if fv.IsNil() {
-						fv.Set(reflect.New(rt.Type))
-					}
-					fv = fv.Elem()
-				}
-				fv = fv.Field(rt.PrimaryKey.Index)
-				if rt.PrimaryKey.IsOneRelation() {
-					rt = rt.PrimaryKey.RelationTable
-				} else {
-					break
+		fv := c.FieldValue(v)
+		for rc := c; rc.IsOneRelation(); rc = rc.RelationKey {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					fv.Set(reflect.New(rc.RelationTable.Type))
 				}
+				fv = fv.Elem()
 			}
+			fv = rc.RelationKey.FieldValue(fv)
 		}
 		if fv.Kind() == reflect.Ptr {
 			if fv.IsNil() {
@@ -597,13 +686,113 @@ func newTable(i interface{}, x map[reflect.Type]*Table) (*Table, error) {
 	return tableOf(t, x)
 }
 
+// column options for ddl and validation
+type Definition struct {
+	Size    int    // size=255, length of varchar
+	Type    string // type=DECIMAL(10,2), sql type instead of the mapping of go type
+	Null    bool   // null, nullable sql.Scanner such as sql.NullString, pointer always nullable
+	Default string // default='', sql expression
+	Unique  bool   // unique
+	Index   string // index=name, same name columns are composite index, created as table_name
+	Check   string // check=`Age`>0, sql expression
+}
+
+// set the key=value option, return the reason if not correct
+func (d *Definition) set(k, v string) string {
+	if v == "" {
+		return "empty option " + k
+	}
+	switch k {
+	case "size":
+		if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+			return "size not positive integer"
+		} else {
+			d.Size = n
+		}
+	case "type":
+		d.Type = v
+	case "default":
+		d.Default = v
+	case "index":
+		d.Index = v
+	case "check":
+		d.Check = v
+	default:
+		return "unknown option " + k
+	}
+	return ""
+}
+
+// split the tag by comma, except in parentheses and single quotes such as type=DECIMAL(10,2)
+func splitTag(tag string) []string {
+	var a []string
+	depth, quoted, start := 0, false, 0
+	for i, r := range tag {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case quoted:
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			a = append(a, tag[start:i])
+			start = i + 1
+		}
+	}
+	return append(a, tag[start:])
+}
+
+type structField struct {
+	reflect.StructField
+	tag    string
+	path   []int
+	prefix string
+}
+
+// exported fields except "-", flatten the embedded struct that has no tag options,
+// the tag name of embedded struct is the column name prefix
+func structFields(t reflect.Type, path []int, prefix string, a []structField) ([]structField, error) {
+	for i, n := 0, t.NumField(); i < n; i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag := f.Tag.Get("scrud")
+		if tag == "" && strings.Index(string(f.Tag), ":") < 0 {
+			tag = string(f.Tag)
+		}
+		if tag == "-" {
+			continue
+		}
+
+		p := make([]int, len(path)+1)
+		copy(p, path)
+		p[len(path)] = i
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && !strings.Contains(tag, ",") &&
+			f.Type != TypeTime && !f.Type.Implements(typeValuer) && !reflect.PtrTo(f.Type).Implements(TypeScanner) {
+			var err error
+			if a, err = structFields(f.Type, p, prefix+tag, a); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		a = append(a, structField{f, tag, p, prefix})
+	}
+	return a, nil
+}
+
 func TableOf(t reflect.Type) (*Table, error) {
 	return tableOf(t, make(map[reflect.Type]*Table))
 }
 
 func tableOf(t reflect.Type, x map[reflect.Type]*Table) (*Table, error) {
 	if t.Kind() != reflect.Struct {
-		return nil, errors.New("table not struct")
+		return nil, &DefinitionError{Field: t.String(), Reason: "not struct"}
 	}
 
 	tmutex.RLock()
@@ -632,80 +821,103 @@ func tableOf(t reflect.Type, x map[reflect.Type]*Table) (*Table, error) {
 		table.Name = i.TableName()
 	}
 
-	for i, n := 0, t.NumField(); i < n; i++ {
-		f := t.Field(i)
-		if f.PkgPath != "" {
-			continue
-		}
+	fields, err := structFields(t, nil, "", nil)
+	if err != nil {
+		return nil, err
+	}
 
-		tag := f.Tag.Get("scrud")
-		if tag == "" && strings.Index(string(f.Tag), ":") < 0 {
-			tag = string(f.Tag)
-		}
-		if tag == "-" {
-			continue
-		}
+	for i, sf := range fields {
+		f, tag := sf.StructField, sf.tag
 
 		c := &Column{
 			Table:  table,
 			Type:   f.Type,
 			Index:  i,
+			Path:   sf.path,
 			Field:  f.Name,
 			Getter: -1,
 			Setter: -1,
+			prefix: sf.prefix,
 		}
 
+		if _, ok := table.FieldMap[f.Name]; ok {
+			return nil, &DefinitionError{Field: c.FullName(), Reason: "field name repeat"}
+		}
 		table.FieldMap[f.Name] = c
 
-		if a := strings.Split(tag, ","); len(a) > 1 {
+		if a := splitTag(tag); len(a) > 1 {
 			tag = a[0]
 			for _, o := range a[1:] {
 				switch o {
 				case "primary_key":
-					if table.PrimaryKey != nil {
-						return nil, errors.New("table: more than one primary_key: " + t.Name())
+					if c.PrimaryKey() {
+						return nil, &DefinitionError{Field: c.FullName(), Reason: "more than one primary_key"}
 					}
-					table.PrimaryKey = c
+					table.PrimaryKeys = append(table.PrimaryKeys, c)
 				case "auto_increment":
 					if table.AutoIncrement != nil {
-						return nil, errors.New("table: more than one auto_increment: " + t.Name())
+						return nil, &DefinitionError{Field: t.Name(), Reason: "more than one auto_increment"}
 					}
 					if autoIncrement(f.Type.Kind()) != 0 {
 						table.AutoIncrement = c
 					} else {
-						return nil, errors.New("table: auto_increment not ints or uints: " + c.FullName())
+						return nil, &DefinitionError{Field: c.FullName(), Reason: "auto_increment not ints or uints"}
 					}
 				case "auto_now_add":
 					if table.AutoNowAdd != nil {
-						return nil, errors.New("table: more than one auto_now_add: " + t.Name())
+						return nil, &DefinitionError{Field: t.Name(), Reason: "more than one auto_now_add"}
 					}
 					if f.Type != TypeTime {
-						return nil, errors.New("table: auto_now_add not time.Time: " + c.FullName())
+						return nil, &DefinitionError{Field: c.FullName(), Reason: "auto_now_add not time.Time"}
 					}
 					if table.AutoNow != nil && table.AutoNow.Index == c.Index {
-						return nil, errors.New("table: auto_now_add and auto_now both appear: " + c.FullName())
+						return nil, &DefinitionError{Field: c.FullName(), Reason: "auto_now_add and auto_now both appear"}
 					}
 					table.AutoNowAdd = c
 				case "auto_now":
 					if table.AutoNow != nil {
-						return nil, errors.New("table: more than one auto_now: " + t.Name())
+						return nil, &DefinitionError{Field: t.Name(), Reason: "more than one auto_now"}
 					}
 					if f.Type != TypeTime {
-						return nil, errors.New("table: auto_now not time.Time: " + c.FullName())
+						return nil, &DefinitionError{Field: c.FullName(), Reason: "auto_now not time.Time"}
 					}
 					if table.AutoNowAdd != nil && table.AutoNowAdd.Index == c.Index {
-						return nil, errors.New("table: auto_now and auto_now_add both appear: " + c.FullName())
+						return nil, &DefinitionError{Field: c.FullName(), Reason: "auto_now and auto_now_add both appear"}
 					}
 					table.AutoNow = c
+				case "soft_delete":
+					if table.SoftDelete != nil {
+						return nil, &DefinitionError{Field: t.Name(), Reason: "more than one soft_delete"}
+					}
+					if f.Type != reflect.PtrTo(TypeTime) {
+						return nil, &DefinitionError{Field: c.FullName(), Reason: "soft_delete not *time.Time"}
+					}
+					table.SoftDelete = c
+				case "version":
+					if table.Version != nil {
+						return nil, &DefinitionError{Field: t.Name(), Reason: "more than one version"}
+					}
+					if autoIncrement(f.Type.Kind()) == 0 {
+						return nil, &DefinitionError{Field: c.FullName(), Reason: "version not ints or uints"}
+					}
+					table.Version = c
 				case "json", "gob":
 					if c.HasEncoding() {
-						return nil, errors.New("table: more than one encoding: " + c.FullName())
+						return nil, &DefinitionError{Field: c.FullName(), Reason: "more than one encoding"}
 					}
 					c.Encoding = o
+				case "null":
+					c.Definition.Null = true
+				case "unique":
+					c.Definition.Unique = true
 				default:
-					if r, ok := relations[o]; ok {
+					if i := strings.Index(o, "="); i > 0 {
+						if reason := c.Definition.set(o[:i], o[i+1:]); reason != "" {
+							return nil, &DefinitionError{Field: c.FullName(), Reason: reason}
+						}
+					} else if r, ok := relations[o]; ok {
 						if c.Relation != 0 {
-							return nil, errors.New("table: more than one relation: " + c.FullName())
+							return nil, &DefinitionError{Field: c.FullName(), Reason: "more than one relation"}
 						}
 
 						ft := f.Type
@@ -717,7 +929,7 @@ func tableOf(t reflect.Type, x map[reflect.Type]*Table) (*Table, error) {
 
 						if isManyRelation(r) {
 							if fk != reflect.Slice {
-								return nil, errors.New("table: many relation not slice: " + c.FullName())
+								return nil, &DefinitionError{Field: c.FullName(), Reason: "many relation not slice"}
 							}
 							ft = ft.Elem()
 							fk = ft.Kind()
@@ -730,7 +942,7 @@ func tableOf(t reflect.Type, x map[reflect.Type]*Table) (*Table, error) {
 						c.Relation = r
 						c.RelationType = ft
 					} else {
-						return nil, fmt.Errorf("table: unknown option: %s/%s", c.FullName(), o)
+						return nil, &DefinitionError{Field: c.FullName(), Reason: "unknown option " + o}
 					}
 				}
 			}
@@ -752,7 +964,7 @@ func tableOf(t reflect.Type, x map[reflect.Type]*Table) (*Table, error) {
 				c.GetType = m.Type.Out(0)
 				c.GetPointer = !has
 			} else {
-				return nil, errors.New("table: getter not correct: " + c.FullName())
+				return nil, &DefinitionError{Field: c.FullName(), Reason: "getter not correct"}
 			}
 		}
 
@@ -766,58 +978,88 @@ func tableOf(t reflect.Type, x map[reflect.Type]*Table) (*Table, error) {
 				c.SetError = o == 1
 				c.SetType = m.Type.In(1)
 			} else {
-				return nil, errors.New("table: setter not correct: " + c.FullName())
+				return nil, &DefinitionError{Field: c.FullName(), Reason: "setter not correct"}
 			}
 		}
 
 		if c.HasEncoding() && (c.HasGetter() || c.HasSetter()) {
-			return nil, errors.New("table: encoding conflict with getter and setter: " + c.FullName())
+			return nil, &DefinitionError{Field: c.FullName(), Reason: "encoding conflict with getter and setter"}
+		}
+		if c.Definition.Null && f.Type.Kind() != reflect.Ptr && !reflect.PtrTo(f.Type).Implements(TypeScanner) {
+			return nil, &DefinitionError{Field: c.FullName(), Reason: "null not pointer or sql.Scanner"}
 		}
 
 		table.Columns = append(table.Columns, c)
 	}
 
 	if len(table.Columns) == 0 {
-		return nil, errors.New("table: no columns: " + t.Name())
+		return nil, &DefinitionError{Field: t.Name(), Reason: "no columns"}
 	}
 
-	if table.PrimaryKey == nil {
+	if len(table.PrimaryKeys) == 0 {
 		if table.AutoIncrement == nil {
 			if c, ok := table.FieldMap["Id"]; ok {
-				if autoIncrement(t.Field(c.Index).Type.Kind()) != 0 {
+				if autoIncrement(c.Type.Kind()) != 0 {
 					table.AutoIncrement = c
 				}
 			}
 		}
 		if table.AutoIncrement != nil {
-			table.PrimaryKey = table.AutoIncrement
+			table.PrimaryKeys = []*Column{table.AutoIncrement}
 		}
 	}
+	if len(table.PrimaryKeys) == 1 {
+		table.PrimaryKey = table.PrimaryKeys[0]
+	}
 
-	if table.PrimaryKey != nil && isManyRelation(table.PrimaryKey.Relation) {
-		return nil, errors.New("table: many relation on primary_key: " + t.Name())
+	for _, c := range table.PrimaryKeys {
+		if isManyRelation(c.Relation) {
+			return nil, &DefinitionError{Field: t.Name(), Reason: "many relation on primary_key"}
+		}
 	}
 
 	if table.AutoIncrement != nil &&
 		(table.AutoIncrement.HasEncoding() || table.AutoIncrement.HasGetter() || table.AutoIncrement.HasSetter()) {
-		return nil, errors.New("table: auto_increment not allow encoding, getter and setter: " + table.AutoIncrement.FullName())
+		return nil, &DefinitionError{Field: table.AutoIncrement.FullName(), Reason: "auto_increment not allow encoding, getter and setter"}
 	}
 
 	if table.AutoNowAdd != nil &&
 		(table.AutoNowAdd.HasEncoding() || table.AutoNowAdd.HasGetter() || table.AutoNowAdd.HasSetter()) {
-		return nil, errors.New("table: auto_now_add not allow encoding, getter and setter: " + table.AutoNowAdd.FullName())
+		return nil, &DefinitionError{Field: table.AutoNowAdd.FullName(), Reason: "auto_now_add not allow encoding, getter and setter"}
 	}
 
 	if table.AutoNow != nil &&
 		(table.AutoNow.HasEncoding() || table.AutoNow.HasGetter() || table.AutoNow.HasSetter()) {
-		return nil, errors.New("table: auto_now not allow encoding, getter and setter: " + table.AutoNow.FullName())
+		return nil, &DefinitionError{Field: table.AutoNow.FullName(), Reason: "auto_now not allow encoding, getter and setter"}
 	}
 
-	if table.PrimaryKey != nil {
-		if err := table.PrimaryKey.init(x); err != nil {
+	if table.SoftDelete != nil {
+		if table.SoftDelete.PrimaryKey() || table.SoftDelete.AutoNowAdd() || table.SoftDelete.AutoNow() {
+			return nil, &DefinitionError{Field: table.SoftDelete.FullName(), Reason: "soft_delete conflict with primary_key, auto_now_add and auto_now"}
+		}
+		if table.SoftDelete.HasEncoding() || table.SoftDelete.HasGetter() || table.SoftDelete.HasSetter() {
+			return nil, &DefinitionError{Field: table.SoftDelete.FullName(), Reason: "soft_delete not allow encoding, getter and setter"}
+		}
+	}
+
+	if table.Version != nil {
+		if table.Version.PrimaryKey() || table.Version.AutoIncrement() {
+			return nil, &DefinitionError{Field: table.Version.FullName(), Reason: "version conflict with primary_key and auto_increment"}
+		}
+		if table.Version.HasEncoding() || table.Version.HasGetter() || table.Version.HasSetter() {
+			return nil, &DefinitionError{Field: table.Version.FullName(), Reason: "version not allow encoding, getter and setter"}
+		}
+	}
+
+	for _, c := range table.PrimaryKeys {
+		if err := c.init(x); err != nil {
 			return nil, err
 		}
 	}
+	table.PrimaryKeys = expandColumns(table.PrimaryKeys)
+	if len(table.PrimaryKeys) > 1 {
+		table.PrimaryKey = nil
+	}
 	for _, c := range table.Columns {
 		if !c.PrimaryKey() {
 			if err := c.init(x); err != nil {
@@ -825,6 +1067,10 @@ func tableOf(t reflect.Type, x map[reflect.Type]*Table) (*Table, error) {
 			}
 		}
 	}
+	table.Columns = expandColumns(table.Columns)
+	for i, c := range table.Columns {
+		c.Index = i
+	}
 
 	tmutex.Lock()
 	tables[t] = table
@@ -5,7 +5,9 @@
 package table
 
 import (
+	"database/sql"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -133,7 +135,7 @@ func TestT4(t *testing.T) {
 		t.Fatal(err)
 	} else if len(t4.Columns) != 2 ||
 		t4.Columns[0].Name != "Id" || !t4.Columns[0].PrimaryKey() ||
-		t4.Columns[1].Name != "T1T4" || t4.Columns[1].NameLeft != "T4Id" || t4.Columns[1].NameRight != "T1Id" ||
+		t4.Columns[1].Name != "T1T4" || strings.Join(t4.Columns[1].NameLeft, "|") != "T4Id" || strings.Join(t4.Columns[1].NameRight, "|") != "T1Id" ||
 		len(t4.ColumnMap) != 1 {
 		t.Fatal("t4")
 	}
@@ -161,7 +163,7 @@ func TestT5(t *testing.T) {
 	} else if len(t5.Columns) != 3 ||
 		t5.Columns[0].Name != "Id" || !t5.Columns[0].PrimaryKey() ||
 		t5.Columns[1].RelationTable == nil || t5.Columns[1].RelationTable.Name != "t2" ||
-		t5.Columns[1].Name != "T2T5" || t5.Columns[1].NameLeft != "T5Id" || t5.Columns[1].NameRight != "T2T1" ||
+		t5.Columns[1].Name != "T2T5" || strings.Join(t5.Columns[1].NameLeft, "|") != "T5Id" || strings.Join(t5.Columns[1].NameRight, "|") != "T2T1" ||
 		t5.Columns[1].ThroughTable == nil || t5.Columns[1].ThroughTable.Name != "T6" ||
 		t5.Columns[1].ThroughLeft.Index != 1 || t5.Columns[1].ThroughRight.Index != 2 ||
 		t5.Columns[2].Name != "Ct" || !t5.Columns[2].AutoNowAdd() {
@@ -169,6 +171,183 @@ func TestT5(t *testing.T) {
 	}
 }
 
+type T7 struct {
+	Id int
+	Dt *time.Time `,soft_delete`
+	Vn uint       `,version`
+}
+
+type T8 struct {
+	Id int
+	Dt string `,soft_delete`
+}
+
+type T8a struct {
+	Id int
+	Dt time.Time `,soft_delete`
+}
+
+func TestSoftDelete(t *testing.T) {
+	if t7, err := NewTable(T7{}); err != nil {
+		t.Fatal(err)
+	} else if t7.SoftDelete == nil || !t7.Columns[1].SoftDelete() {
+		t.Fatal("t7")
+	}
+	if _, err := NewTable(T8{}); err == nil {
+		t.Fatal("t8")
+	}
+	if _, err := NewTable(T8a{}); err == nil {
+		t.Fatal("t8a")
+	}
+}
+
+type T9 struct {
+	Id int
+	Vn string `,version`
+}
+
+func TestVersion(t *testing.T) {
+	if t7, err := NewTable(T7{}); err != nil {
+		t.Fatal(err)
+	} else if t7.Version == nil || !t7.Columns[2].Version() {
+		t.Fatal("t7")
+	}
+	if _, err := NewTable(T9{}); err == nil {
+		t.Fatal("t9")
+	}
+}
+
+type Timestamps struct {
+	Created time.Time `,auto_now_add`
+	Updated time.Time `,auto_now`
+}
+
+type Revision struct {
+	Vn uint `,version`
+}
+
+type T10 struct {
+	Id int
+	Timestamps
+	Audit    Timestamps `,json`
+	Revision `rev_`
+}
+
+func TestEmbedded(t *testing.T) {
+	t10, err := NewTable(T10{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(t10.Columns) != 5 ||
+		t10.AutoNowAdd == nil || t10.AutoNowAdd.Field != "Created" || len(t10.AutoNowAdd.Path) != 2 ||
+		t10.AutoNow == nil || t10.AutoNow.Name != "Updated" ||
+		t10.Columns[3].Field != "Audit" || t10.Columns[3].Encoding != "json" ||
+		t10.Columns[4].Name != "rev_Vn" || !t10.Columns[4].Version() {
+		t.Fatal("t10")
+	}
+	v := reflect.ValueOf(&T10{Timestamps: Timestamps{Created: time.Unix(1, 0)}}).Elem()
+	if i, err := t10.AutoNowAdd.GetValue(v); err != nil {
+		t.Fatal(err)
+	} else if i.(time.Time).Unix() != 1 {
+		t.Fatal(i)
+	}
+	if err := t10.Columns[4].SetValue(v, uint(2)); err != nil {
+		t.Fatal(err)
+	} else if v.Interface().(T10).Vn != 2 {
+		t.Fatal("set value")
+	}
+}
+
+type T11 struct {
+	TenantId int `,primary_key`
+	Id       int `,primary_key`
+	Data     string
+}
+
+type T12 struct {
+	Id  int
+	T11 *T11 `,many_to_one`
+}
+
+type T12a struct {
+	Id  int
+	T11 T11 `Tenant|Parent,one_to_one`
+}
+
+type T12b struct {
+	Id  int
+	T11 T11 `Parent,one_to_one`
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	if t11, err := NewTable(T11{}); err != nil {
+		t.Fatal(err)
+	} else if t11.PrimaryKey != nil || len(t11.PrimaryKeys) != 2 ||
+		!t11.Columns[0].PrimaryKey() || !t11.Columns[1].PrimaryKey() || t11.Columns[2].PrimaryKey() ||
+		t11.AutoIncrement != nil {
+		t.Fatal("t11")
+	}
+	t12, err := NewTable(T12{})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(t12.Columns) != 3 || t12.ColumnMap["T11TenantId"] != t12.Columns[1] || t12.ColumnMap["T11Id"] != t12.Columns[2] ||
+		t12.Columns[1].Field != "T11" || t12.Columns[2].Field != "T11" || t12.Columns[2].Index != 2 ||
+		t12.Columns[1].RelationKey.Field != "TenantId" || t12.Columns[2].RelationKey.Field != "Id" ||
+		t12.FieldMap["T11"] != t12.Columns[1] || len(t12.Columns[2].FieldColumns()) != 2 {
+		t.Fatal("t12")
+	}
+	v := reflect.ValueOf(&T12{}).Elem()
+	if err := t12.Columns[2].SetValue(v, 2); err != nil {
+		t.Fatal(err)
+	} else if i, err := t12.Columns[1].GetValue(v); err != nil || i != 0 {
+		t.Fatal(i, err)
+	} else if i, err := t12.Columns[2].GetValue(v); err != nil || i != 2 {
+		t.Fatal(i, err)
+	}
+	if t12a, err := NewTable(T12a{}); err != nil {
+		t.Fatal(err)
+	} else if t12a.Columns[1].Name != "Tenant" || t12a.Columns[2].Name != "Parent" {
+		t.Fatal("t12a")
+	}
+	if _, err := NewTable(T12b{}); err == nil {
+		t.Fatal("t12b")
+	}
+}
+
+type T13 struct {
+	Id    int
+	Name  string          `,size=64,unique,index=name_age`
+	Age   int             "scrud:\",default=0,check=`Age`>=0,index=name_age\""
+	Price sql.NullFloat64 `,type=DECIMAL(10,2),null`
+}
+
+type T13a struct {
+	Id    int
+	Price float64 `,null`
+}
+
+func TestDefinition(t *testing.T) {
+	t13, err := NewTable(T13{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := t13.Columns[1].Definition; d.Size != 64 || !d.Unique || d.Index != "name_age" {
+		t.Fatal(d)
+	}
+	if d := t13.Columns[2].Definition; d.Default != "0" || d.Check != "`Age`>=0" || d.Index != "name_age" {
+		t.Fatal(d)
+	}
+	if d := t13.Columns[3].Definition; d.Type != "DECIMAL(10,2)" || !d.Null {
+		t.Fatal(d)
+	}
+	if _, err := NewTable(T13a{}); err == nil {
+		t.Fatal("t13a")
+	}
+	if a := splitTag("a,type=DECIMAL(10,2),default='x,y'"); len(a) != 3 || a[2] != "default='x,y'" {
+		t.Fatal(a)
+	}
+}
+
 type Node struct {
 	Id       int
 	Parent   *Node   `,foreign_key`
@@ -184,7 +363,7 @@ func TestNode(t *testing.T) {
 		len(node.Columns) != 4 || len(node.ColumnMap) != 2 ||
 		node.Columns[1].Name != "NodeId" ||
 		node.Columns[2].Name != "NodeId" ||
-		node.Columns[3].Name != "TableName" || node.Columns[3].NameLeft != "LeftId" || node.Columns[3].NameRight != "RightId" {
+		node.Columns[3].Name != "TableName" || strings.Join(node.Columns[3].NameLeft, "|") != "LeftId" || strings.Join(node.Columns[3].NameRight, "|") != "RightId" {
 		t.Fatal("node")
 	}
 }
@@ -0,0 +1,95 @@
+package scrud
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/cxr29/scrud/internal/table"
+	. "github.com/cxr29/scrud/query"
+)
+
+// select by primary key, and the one relation fields by left join in one query,
+// nested relation separated by dot such as "Customer.Address"
+func (db *DB) SelectWith(data interface{}, fields ...string) error {
+	return selectWith(context.Background(), db, data, fields...)
+}
+
+// same as SelectWith with context
+func (db *DB) SelectWithContext(ctx context.Context, data interface{}, fields ...string) error {
+	return selectWith(ctx, db, data, fields...)
+}
+
+func (tx *Tx) SelectWith(data interface{}, fields ...string) error {
+	return selectWith(context.Background(), tx, data, fields...)
+}
+
+func (tx *Tx) SelectWithContext(ctx context.Context, data interface{}, fields ...string) error {
+	return selectWith(ctx, tx, data, fields...)
+}
+
+func selectWith(ctx context.Context, xr faker, data interface{}, fields ...string) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Ptr {
+		return newError(ErrInvalidData, "scrud: select with need pointer")
+	}
+	if v.IsNil() {
+		return newError(ErrNilData, "scrud: select with nil")
+	}
+	v = v.Elem()
+
+	x, err := table.TableOf(v.Type())
+	if err != nil {
+		return err
+	}
+
+	pk, err := pkCond("select with", x, columnNames(x.PrimaryKeys, x.Name), v)
+	if err != nil {
+		return err
+	}
+
+	q := Select().From(x.Name)
+	for _, c := range x.Columns {
+		if !c.IsManyRelation() {
+			q.Select(x.Name + "." + c.Name)
+		}
+	}
+
+	joined := make(map[string]struct{})
+	for _, f := range fields {
+		t, alias, prefix := x, x.Name, ""
+		for _, name := range strings.Split(f, ".") {
+			c := t.FindField(name)
+			if c == nil {
+				return &ColumnNotFoundError{"select with", t.Type.Name(), name}
+			} else if !c.IsOneRelation() {
+				return newError(ErrNotRelation, "scrud: select with not one relation: "+c.FullName())
+			}
+
+			rt, parent := c.RelationTable, alias
+			alias = x.Name + "_" + strings.Replace(prefix+c.Field, ".", "_", -1) // avoid self join conflict
+			prefix += c.Field + "."
+
+			if _, ok := joined[alias]; !ok {
+				joined[alias] = struct{}{}
+				var on []Condition
+				for _, fc := range c.FieldColumns() {
+					on = append(on, Cond(BackQuote(alias+"."+fc.RelationKey.Name)+"="+BackQuote(parent+"."+fc.Name)))
+				}
+				q.LeftJoin(Expr(BackQuote(rt.Name)+" AS "+BackQuote(alias)), scopeAs(xr, rt, alias, on...))
+				for _, rc := range rt.Columns {
+					if !rc.IsManyRelation() {
+						q.Select(Expr(BackQuote(alias+"."+rc.Name) + " AS " +
+							BackQuote(strings.Replace(prefix+rc.Name, ".", "..", -1))))
+					}
+				}
+			}
+
+			t = rt
+		}
+	}
+
+	q.Where(scopeAs(xr, x, x.Name, pk))
+
+	return fetch(ctx, xr, q).One(data)
+}
@@ -1,8 +1,7 @@
 package scrud
 
 import (
-	"errors"
-	"fmt"
+	"context"
 	"reflect"
 
 	"github.com/cxr29/scrud/internal/table"
@@ -36,7 +35,7 @@ func newManyToMany(xr faker, field string, data interface{}) *ManyToMany {
 	t := v.Type()
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
-			return errManyToMany(errors.New("scrud: many to many nil"))
+			return errManyToMany(newError(ErrNilData, "scrud: many to many nil"))
 		}
 		v = v.Elem()
 		t = v.Type()
@@ -46,158 +45,183 @@ func newManyToMany(xr faker, field string, data interface{}) *ManyToMany {
 		return errManyToMany(err)
 	}
 	if c := x.FindField(field); c == nil {
-		return errManyToMany(fmt.Errorf("scrud: many to many column not found: %s/%s", x.Type.Name(), field))
+		return errManyToMany(&ColumnNotFoundError{"many to many", x.Type.Name(), field})
 	} else if c.Relation != table.ManyToMany {
-		return errManyToMany(errors.New("scrud: not many to many column: " + c.FullName()))
+		return errManyToMany(newError(ErrNotRelation, "scrud: not many to many column: "+c.FullName()))
 	} else {
 		return &ManyToMany{xr: xr, table: x, column: c, value: v}
 	}
 }
 
+// the join table of the many to many column and its columns refer to the left and right primary keys
+func joinColumns(c *table.Column) (string, []string, []string) {
+	if c.ThroughTable != nil {
+		return c.ThroughTable.Name, columnNames(c.ThroughLeft.FieldColumns(), ""), columnNames(c.ThroughRight.FieldColumns(), "")
+	}
+	return c.Name, c.NameLeft, c.NameRight
+}
+
 func (m2m *ManyToMany) Empty() error {
+	return m2m.EmptyContext(context.Background())
+}
+
+func (m2m *ManyToMany) EmptyContext(ctx context.Context) error {
 	if m2m.err != nil {
 		return m2m.err
 	}
-	left, err := m2m.table.PrimaryKey.GetValue(m2m.value)
+	name, left, _ := joinColumns(m2m.column)
+	where, err := pkCond("many to many", m2m.table, left, m2m.value)
 	if err != nil {
 		return err
 	}
-	var q Expression
-	if m2m.column.ThroughTable != nil {
-		q = Delete(m2m.column.ThroughTable.Name).Where(Eq(m2m.column.ThroughLeft.Name, left))
-	} else {
-		q = Delete(m2m.column.Name).Where(Eq(m2m.column.NameLeft, left))
-	}
-	_, err = m2m.xr.Run(q)
+	_, err = run(ctx, m2m.xr, Delete(name).Where(where))
 	return err
 }
 
-func (m2m *ManyToMany) right(data interface{}) (interface{}, error) {
+func (m2m *ManyToMany) right(data interface{}) (reflect.Value, error) {
 	v := reflect.ValueOf(data)
 	t := v.Type()
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
-			return nil, errors.New("scrud: many to many nil: " + m2m.column.FullName())
+			return v, newError(ErrNilData, "scrud: many to many nil: "+m2m.column.FullName())
 		}
 		v = v.Elem()
 		t = v.Type()
 	}
 	if t != m2m.column.RelationTable.Type {
-		return nil, errors.New("scrud: many to many type mismatching: " + m2m.column.FullName())
+		return v, newError(ErrInvalidData, "scrud: many to many type mismatching: "+m2m.column.FullName())
 	}
-	return m2m.column.RelationTable.PrimaryKey.GetValue(v)
+	return v, nil
 }
 
 // data should be the relation's type
 func (m2m *ManyToMany) Has(data interface{}) (bool, error) {
+	return m2m.HasContext(context.Background(), data)
+}
+
+func (m2m *ManyToMany) HasContext(ctx context.Context, data interface{}) (bool, error) {
 	if m2m.err != nil {
 		return false, m2m.err
 	}
 
-	right, err := m2m.right(data)
+	w, err := m2m.right(data)
 	if err != nil {
 		return false, err
 	}
 
-	left, err := m2m.table.PrimaryKey.GetValue(m2m.value)
+	rt := m2m.column.RelationTable
+	name, left, right := joinColumns(m2m.column)
+	l, err := pkCond("many to many", m2m.table, left, m2m.value)
+	if err != nil {
+		return false, err
+	}
+	r, err := pkCond("many to many", rt, right, w)
 	if err != nil {
 		return false, err
 	}
 
-	query := Count()
+	query := Count().From(name)
 	if m2m.column.ThroughTable != nil {
-		query.From(m2m.column.ThroughTable.Name).Where(
-			Eq(m2m.column.ThroughLeft.Name, left),
-			Eq(m2m.column.ThroughRight.Name, right),
-		)
+		query.Where(scope(m2m.xr, m2m.column.ThroughTable, l, r))
 	} else {
-		query.From(m2m.column.Name).Where(
-			Eq(m2m.column.NameLeft, left),
-			Eq(m2m.column.NameRight, right),
-		)
+		query.Where(l, r)
+	}
+	if rt.SoftDelete != nil && !m2m.xr.options().unscoped {
+		pk, err := pkCond("many to many", rt, nil, w)
+		if err != nil {
+			return false, err
+		}
+		query.Where(Exists(Select(rt.PrimaryKeys[0].Name).From(rt.Name).Where(scope(m2m.xr, rt, pk))))
 	}
 	query.Limit(1)
 
 	var n int
-	if err := m2m.xr.Fetch(query).Row(&n); err != nil {
+	if err := fetch(ctx, m2m.xr, query).Row(&n); err != nil {
 		return false, err
 	}
 	return n == 1, nil
 }
 
-func (m2m *ManyToMany) set(empty bool, data ...interface{}) error {
+func (m2m *ManyToMany) set(ctx context.Context, empty bool, data ...interface{}) error {
 	if m2m.err != nil {
 		return m2m.err
 	}
 
-	left, err := m2m.table.PrimaryKey.GetValue(m2m.value)
+	left, err := pkValues("many to many", m2m.table, m2m.value)
 	if err != nil {
 		return err
 	}
 
-	q := Insert(m2m.column.Name).Columns(m2m.column.NameLeft, m2m.column.NameRight)
-	if m2m.column.ThroughTable != nil {
-		q = Insert(m2m.column.ThroughTable.Name).Columns(m2m.column.ThroughLeft.Name, m2m.column.ThroughRight.Name)
-	}
+	name, l, r := joinColumns(m2m.column)
+	q := Insert(name).Columns(append(append([]string{}, l...), r...)...)
 	for _, i := range data {
-		right, err := m2m.right(i)
+		w, err := m2m.right(i)
+		if err != nil {
+			return err
+		}
+		right, err := pkValues("many to many", m2m.column.RelationTable, w)
 		if err != nil {
 			return err
 		}
-		q.Values(left, right)
+		q.Values(append(append([]interface{}{}, left...), right...)...)
 	}
 
 	if empty {
-		if err := m2m.Empty(); err != nil {
+		if err := m2m.EmptyContext(ctx); err != nil {
 			return err
 		}
 	}
 
-	_, err = m2m.xr.Run(q)
+	_, err = run(ctx, m2m.xr, q)
 	return err
 }
 
 // data should be the relation's type
 func (m2m *ManyToMany) Set(data ...interface{}) error {
-	return m2m.set(true, data...)
+	return m2m.set(context.Background(), true, data...)
+}
+
+func (m2m *ManyToMany) SetContext(ctx context.Context, data ...interface{}) error {
+	return m2m.set(ctx, true, data...)
 }
 
 // data should be the relation's type
 func (m2m *ManyToMany) Add(data ...interface{}) error {
-	return m2m.set(false, data...)
+	return m2m.set(context.Background(), false, data...)
+}
+
+func (m2m *ManyToMany) AddContext(ctx context.Context, data ...interface{}) error {
+	return m2m.set(ctx, false, data...)
 }
 
 // data should be the relation's type
 func (m2m *ManyToMany) Remove(data ...interface{}) error {
+	return m2m.RemoveContext(context.Background(), data...)
+}
+
+func (m2m *ManyToMany) RemoveContext(ctx context.Context, data ...interface{}) error {
 	if m2m.err != nil {
 		return m2m.err
 	}
-	left, err := m2m.table.PrimaryKey.GetValue(m2m.value)
+	name, left, right := joinColumns(m2m.column)
+	l, err := pkCond("many to many", m2m.table, left, m2m.value)
 	if err != nil {
 		return err
 	}
 
-	a := make([]interface{}, 0, len(data))
+	a := make([][]interface{}, 0, len(data))
 	for _, i := range data {
-		right, err := m2m.right(i)
+		w, err := m2m.right(i)
+		if err != nil {
+			return err
+		}
+		k, err := pkValues("many to many", m2m.column.RelationTable, w)
 		if err != nil {
 			return err
 		}
-		a = append(a, right)
+		a = append(a, k)
 	}
 
-	var q Expression
-	if m2m.column.ThroughTable != nil {
-		q = Delete(m2m.column.ThroughTable.Name).Where(
-			Eq(m2m.column.ThroughLeft.Name, left),
-			In(m2m.column.ThroughRight.Name, a...),
-		)
-	} else {
-		q = Delete(m2m.column.Name).Where(
-			Eq(m2m.column.NameLeft, left),
-			In(m2m.column.NameRight, a...),
-		)
-	}
-	_, err = m2m.xr.Run(q)
+	_, err = run(ctx, m2m.xr, Delete(name).Where(l, keysCond(right, a)))
 	return err
 }
@@ -1,7 +1,6 @@
 package scrud
 
 import (
-	"errors"
 	"reflect"
 
 	"github.com/cxr29/scrud/internal/table"
@@ -13,7 +12,7 @@ func StructToMap(data interface{}, columns ...string) (map[string]interface{}, e
 	t := v.Type()
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
-			return nil, errors.New("scrud: struct to map nil")
+			return nil, newError(ErrNilData, "scrud: struct to map nil")
 		}
 		v = v.Elem()
 		t = v.Type()
@@ -42,7 +41,7 @@ func StructToMap(data interface{}, columns ...string) (map[string]interface{}, e
 			}
 		}
 		if c.HasEncoding() {
-			m[c.Name] = v.Field(c.Index).Interface()
+			m[c.Name] = c.FieldValue(v).Interface()
 		} else if i, err := c.GetValue(v); err != nil {
 			return nil, err
 		} else {
@@ -58,10 +57,10 @@ func MapToStruct(data interface{}, m map[string]interface{}) error {
 	v := reflect.ValueOf(data)
 	t := v.Type()
 	if v.Kind() != reflect.Ptr {
-		return errors.New("scrud: map to struct need pointer")
+		return newError(ErrInvalidData, "scrud: map to struct need pointer")
 	}
 	if v.IsNil() {
-		return errors.New("scrud: map to struct nil")
+		return newError(ErrNilData, "scrud: map to struct nil")
 	}
 	v = v.Elem()
 	t = v.Type()
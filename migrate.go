@@ -0,0 +1,272 @@
+package scrud
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cxr29/scrud/internal/table"
+	. "github.com/cxr29/scrud/query"
+)
+
+// column of the table in the database
+type dbColumn struct {
+	typ  string // as the database report, such as varchar(64)
+	null bool
+}
+
+// return the columns of the table in the database, empty if the table not exists
+func tableColumns(ctx context.Context, xr faker, name string) (map[string]dbColumn, error) {
+	driverName := xr.Starter().DriverName()
+
+	var q Expression
+	switch driverName {
+	case "mysql":
+		q = Select("column_name", "column_type", "is_nullable").From(Expr("`information_schema.columns`")).Where(
+			Cond("`table_schema`=DATABASE()"),
+			Eq("table_name", name),
+		)
+	case "postgres":
+		q = Select("column_name", "data_type", "character_maximum_length", "numeric_precision", "numeric_scale", "is_nullable").
+			From(Expr("`information_schema.columns`")).Where(
+			Cond("`table_schema`=current_schema()"),
+			Eq("table_name", name),
+		)
+	case "sqlite":
+		q = Select("name", "type", "notnull").From(Expr("pragma_table_info(?)", name))
+	}
+
+	rows := fetch(ctx, xr, q)
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[string]dbColumn)
+	for rows.Next() {
+		var s, typ, nullable string
+		var err error
+		switch driverName {
+		case "postgres":
+			var size, precision, scale sql.NullInt64
+			err = rows.Rows.Scan(&s, &typ, &size, &precision, &scale, &nullable)
+			if size.Valid {
+				typ += "(" + strconv.FormatInt(size.Int64, 10) + ")"
+			} else if typ == "numeric" && precision.Valid {
+				typ += "(" + strconv.FormatInt(precision.Int64, 10) + "," + strconv.FormatInt(scale.Int64, 10) + ")"
+			}
+		case "sqlite":
+			var notNull int
+			err = rows.Rows.Scan(&s, &typ, &notNull)
+			if notNull == 0 {
+				nullable = "YES"
+			}
+		default:
+			err = rows.Rows.Scan(&s, &typ, &nullable)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[s] = dbColumn{typ, nullable == "YES"}
+	}
+	return m, rows.Err()
+}
+
+// return the index names of the table in the database
+func tableIndexes(ctx context.Context, xr faker, name string) (map[string]struct{}, error) {
+	var q Expression
+	switch xr.Starter().DriverName() {
+	case "mysql":
+		q = Select("index_name").From(Expr("`information_schema.statistics`")).Where(
+			Cond("`table_schema`=DATABASE()"),
+			Eq("table_name", name),
+		)
+	case "postgres":
+		q = Select("indexname").From("pg_indexes").Where(
+			Cond("`schemaname`=current_schema()"),
+			Eq("tablename", name),
+		)
+	case "sqlite":
+		q = Select("name").From(Expr("pragma_index_list(?)", name))
+	}
+
+	rows := fetch(ctx, xr, q)
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[string]struct{})
+	for rows.Next() {
+		var s string
+		if err := rows.Rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		m[s] = struct{}{}
+	}
+	return m, rows.Err()
+}
+
+var intWidth = regexp.MustCompile(`\b(tinyint|smallint|mediumint|int|bigint)\(\d+\)`)
+
+// postgres type aliases to the names of information_schema
+var postgresTypes = map[string]string{
+	"varchar":     "character varying",
+	"char":        "character",
+	"decimal":     "numeric",
+	"int":         "integer",
+	"int2":        "smallint",
+	"int4":        "integer",
+	"int8":        "bigint",
+	"float4":      "real",
+	"float8":      "double precision",
+	"bool":        "boolean",
+	"timestamp":   "timestamp without time zone",
+	"timestamptz": "timestamp with time zone",
+}
+
+// compare the sql type of the model and of the database, case insensitive,
+// ignore mysql integer display width except tinyint(1), resolve postgres aliases
+func sameType(driverName, want, have string) bool {
+	normalize := func(s string) string {
+		s = strings.ToLower(strings.Join(strings.Fields(s), " "))
+		switch driverName {
+		case "mysql":
+			s = intWidth.ReplaceAllStringFunc(s, func(i string) string {
+				if i == "tinyint(1)" {
+					return i
+				}
+				return i[:strings.Index(i, "(")]
+			})
+		case "postgres":
+			name, args := s, ""
+			if i := strings.Index(s, "("); i != -1 {
+				name, args = s[:i], strings.Replace(s[i:], " ", "", -1)
+			}
+			if i, ok := postgresTypes[name]; ok {
+				name = i
+			}
+			s = name + args
+		}
+		return s
+	}
+	return normalize(want) == normalize(have)
+}
+
+func nullString(typ string, null bool) string {
+	if null {
+		return typ + " NULL"
+	}
+	return typ + " NOT NULL"
+}
+
+// compare the models' tables to the database,
+// return query expressions in order to create the missing tables, add the missing columns and indexes,
+// and alter the columns of type or nullability drift, sqlite can't alter column so report the drift by DriftError
+func migrate(ctx context.Context, xr faker, dryRun bool, models ...interface{}) ([]Expression, error) {
+	driverName := xr.Starter().DriverName()
+
+	plan := make([]Expression, 0)
+	drifts := make([]ColumnDrift, 0)
+	done := make(map[string]struct{})
+	for _, i := range models {
+		x, err := table.NewTable(i)
+		if err != nil {
+			return nil, err
+		}
+
+		defs, err := definitionsOf(driverName, x)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range defs {
+			if _, ok := done[d.name]; ok {
+				continue
+			}
+			done[d.name] = struct{}{}
+
+			m, err := tableColumns(ctx, xr, d.name)
+			if err != nil {
+				return nil, err
+			}
+			if len(m) == 0 {
+				plan = append(plan, d.create()...)
+				continue
+			}
+			for k, c := range d.columns {
+				i, ok := m[c]
+				if !ok {
+					a, err := d.add(k)
+					if err != nil {
+						return nil, err
+					}
+					plan = append(plan, a...)
+					continue
+				}
+				typ := d.types[k]
+				if typ == "" {
+					continue
+				}
+				typeDrift, nullDrift := !sameType(driverName, typ, i.typ), d.nulls[k] != i.null
+				if !typeDrift && !nullDrift {
+					continue
+				}
+				if q := d.alter(k, typeDrift, nullDrift); q != nil {
+					plan = append(plan, q)
+				} else {
+					drifts = append(drifts, ColumnDrift{d.name, c, nullString(typ, d.nulls[k]), nullString(i.typ, i.null)})
+				}
+			}
+
+			if len(d.indexes) == 0 {
+				continue
+			}
+			indexes, err := tableIndexes(ctx, xr, d.name)
+			if err != nil {
+				return nil, err
+			}
+			for _, i := range d.indexes {
+				if _, ok := indexes[i.name]; !ok {
+					plan = append(plan, d.createIndex(i))
+				}
+			}
+		}
+	}
+
+	if !dryRun {
+		if err := runAll(ctx, xr, plan, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(drifts) > 0 {
+		return plan, &DriftError{drifts}
+	}
+	return plan, nil
+}
+
+// create the missing tables, add the missing columns and indexes and alter the drifted columns of the models,
+// include the implicit many to many tables,
+// the added NOT NULL column without default option use the go zero value as default, error if not known
+//
+// return the plan, if dry run only return not apply,
+// sqlite can't alter column, the existing columns that differ in type or nullability return *DriftError with the plan
+func (db *DB) Migrate(dryRun bool, models ...interface{}) ([]Expression, error) {
+	return migrate(context.Background(), db, dryRun, models...)
+}
+
+// same as Migrate with context
+func (db *DB) MigrateContext(ctx context.Context, dryRun bool, models ...interface{}) ([]Expression, error) {
+	return migrate(ctx, db, dryRun, models...)
+}
+
+func (tx *Tx) Migrate(dryRun bool, models ...interface{}) ([]Expression, error) {
+	return migrate(context.Background(), tx, dryRun, models...)
+}
+
+func (tx *Tx) MigrateContext(ctx context.Context, dryRun bool, models ...interface{}) ([]Expression, error) {
+	return migrate(ctx, tx, dryRun, models...)
+}
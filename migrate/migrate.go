@@ -0,0 +1,235 @@
+// Copyright 2015 Chen Xianren. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// Versioned migrations
+//
+//  import "github.com/cxr29/scrud/migrate"
+//
+//  // usually register in init beside the models
+//  migrate.RegisterSQL(1, "CREATE TABLE `a` (...)", "DROP TABLE `a`")
+//  migrate.Register(2, func(tx *scrud.Tx) error { ... }, func(tx *scrud.Tx) error { ... })
+//
+//  err := migrate.Up(db)          // run pending migrations in version order
+//  err = migrate.Down(db, 1)      // rollback applied migrations after version 1
+//  a, err := migrate.Status(db)   // registered and applied versions
+//
+// each migration run in its own transaction with the applied version recorded,
+// but notice mysql implicit commit data definition statements
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cxr29/scrud"
+	. "github.com/cxr29/scrud/query"
+)
+
+// bookkeeping table name, change it before first use
+var TableName = "scrud_migration"
+
+// bookkeeping row of an applied version
+type Version struct {
+	Version   int64     `,primary_key`
+	AppliedAt time.Time `,auto_now_add`
+}
+
+func (_ *Version) TableName() string {
+	return TableName
+}
+
+type Migration struct {
+	Version  int64
+	Up, Down func(*scrud.Tx) error // down nil if irreversible
+}
+
+var (
+	mutex      = new(sync.RWMutex)
+	migrations = make(map[int64]*Migration)
+)
+
+// register a numbered migration, panic if version not positive, up nil or version repeat
+func Register(version int64, up, down func(*scrud.Tx) error) {
+	if version <= 0 {
+		panic("migrate: version not positive: " + strconv.FormatInt(version, 10))
+	}
+	if up == nil {
+		panic("migrate: up nil: " + strconv.FormatInt(version, 10))
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, ok := migrations[version]; ok {
+		panic("migrate: version repeat: " + strconv.FormatInt(version, 10))
+	}
+	migrations[version] = &Migration{version, up, down}
+}
+
+// register a numbered migration of raw sql, down empty if irreversible
+//
+// sql expand as query.Expr, so back quote identifier is portable and double question mark for literal
+func RegisterSQL(version int64, up, down string) {
+	var d func(*scrud.Tx) error
+	if down != "" {
+		d = runSQL(down)
+	}
+	Register(version, runSQL(up), d)
+}
+
+func runSQL(s string) func(*scrud.Tx) error {
+	return func(tx *scrud.Tx) error {
+		_, err := tx.Run(Expr(s))
+		return err
+	}
+}
+
+// registered migrations in version order
+func sorted() []*Migration {
+	mutex.RLock()
+	a := make([]*Migration, 0, len(migrations))
+	for _, m := range migrations {
+		a = append(a, m)
+	}
+	mutex.RUnlock()
+
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Version < a[j].Version
+	})
+	return a
+}
+
+func applied(db *scrud.DB) (map[int64]time.Time, error) {
+	if err := db.CreateTable(new(Version)); err != nil {
+		return nil, err
+	}
+
+	var a []*Version
+	if err := db.Fetch(Select().From(TableName)).All(&a); err != nil {
+		return nil, err
+	}
+
+	m := make(map[int64]time.Time, len(a))
+	for _, v := range a {
+		m[v.Version] = v.AppliedAt
+	}
+	return m, nil
+}
+
+func transact(db *scrud.DB, f func(*scrud.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// run the pending migrations in version order
+func Up(db *scrud.DB) error {
+	m, err := applied(db)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range sorted() {
+		if _, ok := m[i.Version]; ok {
+			continue
+		}
+		if err := transact(db, func(tx *scrud.Tx) error {
+			if err := i.Up(tx); err != nil {
+				return err
+			}
+			_, err := tx.Insert(&Version{Version: i.Version})
+			return err
+		}); err != nil {
+			return fmt.Errorf("migrate: up %d: %w", i.Version, err)
+		}
+	}
+
+	return nil
+}
+
+// rollback the applied migrations after the target version in reverse order
+func Down(db *scrud.DB, target int64) error {
+	m, err := applied(db)
+	if err != nil {
+		return err
+	}
+
+	a := make([]int64, 0, len(m))
+	for v := range m {
+		if v > target {
+			a = append(a, v)
+		}
+	}
+	sort.Slice(a, func(i, j int) bool {
+		return a[i] > a[j]
+	})
+
+	mutex.RLock()
+	for _, v := range a {
+		if i, ok := migrations[v]; !ok {
+			mutex.RUnlock()
+			return errors.New("migrate: version not registered: " + strconv.FormatInt(v, 10))
+		} else if i.Down == nil {
+			mutex.RUnlock()
+			return errors.New("migrate: irreversible: " + strconv.FormatInt(v, 10))
+		}
+	}
+	mutex.RUnlock()
+
+	for _, v := range a {
+		mutex.RLock()
+		i := migrations[v]
+		mutex.RUnlock()
+		if err := transact(db, func(tx *scrud.Tx) error {
+			if err := i.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&Version{Version: v})
+		}); err != nil {
+			return fmt.Errorf("migrate: down %d: %w", v, err)
+		}
+	}
+
+	return nil
+}
+
+type State struct {
+	Version    int64
+	Registered bool
+	Applied    bool
+	AppliedAt  time.Time
+}
+
+// registered and applied versions in version order
+func Status(db *scrud.DB) ([]State, error) {
+	m, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	a := make([]State, 0, len(m))
+	for _, i := range sorted() {
+		t, ok := m[i.Version]
+		a = append(a, State{i.Version, true, ok, t})
+		delete(m, i.Version)
+	}
+	for v, t := range m {
+		a = append(a, State{v, false, true, t})
+	}
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Version < a[j].Version
+	})
+
+	return a, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2015 Chen Xianren. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+// set data source name environment variable TestMySQLScrud to test mysql
+package migrate
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cxr29/scrud"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+func TestMySQLMigrate(t *testing.T) {
+	dsn := os.Getenv("TestMySQLScrud")
+	if dsn == "" {
+		t.SkipNow()
+	}
+
+	db, err := scrud.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := db.DropTable(new(Version)); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	RegisterSQL(1, "CREATE TABLE `scrud_migrate` (`id` INT NOT NULL)", "DROP TABLE `scrud_migrate`")
+	RegisterSQL(2, "INSERT INTO `scrud_migrate` (`id`) VALUES (1)", "DELETE FROM `scrud_migrate`")
+
+	if err := Up(db); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM `scrud_migrate`").Scan(&n); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal("up")
+	}
+
+	if a, err := Status(db); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || !a[0].Applied || !a[1].Applied || a[1].AppliedAt.IsZero() {
+		t.Fatal("status")
+	}
+
+	if err := Down(db, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM `scrud_migrate`").Scan(&n); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal("down")
+	}
+
+	if err := Down(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := Status(db); err != nil {
+		t.Fatal(err)
+	} else if len(a) != 2 || a[0].Applied || a[1].Applied {
+		t.Fatal("status after down")
+	}
+}
@@ -0,0 +1,119 @@
+package scrud
+
+import (
+	"reflect"
+
+	"github.com/cxr29/scrud/internal/table"
+)
+
+// read-only metadata of the struct table, see Model
+type Schema struct {
+	Type        reflect.Type
+	Name        string // table name
+	Columns     []*Column
+	PrimaryKeys []string // column names, more than one if composite primary key
+	table       *table.Table
+}
+
+// read-only metadata of the struct field
+type Column struct {
+	Field         string
+	Name          string       // column name, many_to_many is the join table name
+	Type          reflect.Type // go type of the field
+	PrimaryKey    bool
+	AutoIncrement bool
+	AutoNowAdd    bool
+	AutoNow       bool
+	SoftDelete    bool
+	Version       bool
+	Encoding      string // json or gob
+	Relation      string // one_to_one, one_to_many, many_to_one or many_to_many
+	Definition    Definition
+	// many_to_many only, the through table if has, the columns are one per primary key
+	JoinTable           string
+	JoinLeft, JoinRight []string
+	column              *table.Column
+}
+
+var relationNames = map[int]string{
+	table.OneToOne:   "one_to_one",
+	table.OneToMany:  "one_to_many",
+	table.ManyToOne:  "many_to_one",
+	table.ManyToMany: "many_to_many",
+}
+
+// metadata of struct, pointer or slice of struct, without touching the database
+func Model(data interface{}) (*Schema, error) {
+	x, err := table.NewTable(data)
+	if err != nil {
+		return nil, err
+	}
+	return newSchema(x), nil
+}
+
+func newSchema(x *table.Table) *Schema {
+	s := &Schema{
+		Type:        x.Type,
+		Name:        x.Name,
+		Columns:     make([]*Column, len(x.Columns)),
+		PrimaryKeys: make([]string, len(x.PrimaryKeys)),
+		table:       x,
+	}
+	for i, c := range x.PrimaryKeys {
+		s.PrimaryKeys[i] = c.Name
+	}
+	for i, c := range x.Columns {
+		m := &Column{
+			Field:         c.Field,
+			Name:          c.Name,
+			Type:          c.Type,
+			PrimaryKey:    c.PrimaryKey(),
+			AutoIncrement: c.AutoIncrement(),
+			AutoNowAdd:    c.AutoNowAdd(),
+			AutoNow:       c.AutoNow(),
+			SoftDelete:    c.SoftDelete(),
+			Version:       c.Version(),
+			Encoding:      c.Encoding,
+			Relation:      relationNames[c.Relation],
+			Definition:    c.Definition,
+			column:        c,
+		}
+		if c.Relation == table.ManyToMany {
+			m.JoinTable, m.JoinLeft, m.JoinRight = joinColumns(c)
+		}
+		s.Columns[i] = m
+	}
+	return s
+}
+
+// field name then column name, nil if not found
+func (s *Schema) Field(name string) *Column {
+	if c := s.table.FindField(name); c != nil {
+		return s.Columns[c.Index]
+	}
+	return nil
+}
+
+// column name then field name, nil if not found
+func (s *Schema) Column(name string) *Column {
+	if c := s.table.FindColumn(name); c != nil {
+		return s.Columns[c.Index]
+	}
+	return nil
+}
+
+// the model of the relation, nil if no relation
+func (c *Column) Related() *Schema {
+	if c.column.RelationTable == nil {
+		return nil
+	}
+	return newSchema(c.column.RelationTable)
+}
+
+// the model of the many_to_many through table, nil if no through
+func (c *Column) Through() *Schema {
+	if c.column.ThroughTable == nil {
+		return nil
+	}
+	return newSchema(c.column.ThroughTable)
+}
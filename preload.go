@@ -0,0 +1,440 @@
+package scrud
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/cxr29/scrud/internal/table"
+	. "github.com/cxr29/scrud/query"
+)
+
+// load relation fields of struct or slice of struct, one query per relation,
+// nested relation separated by dot such as "Customer.Address"
+func (db *DB) Preload(data interface{}, fields ...string) error {
+	return preload(context.Background(), db, data, fields...)
+}
+
+// same as Preload with context
+func (db *DB) PreloadContext(ctx context.Context, data interface{}, fields ...string) error {
+	return preload(ctx, db, data, fields...)
+}
+
+func (tx *Tx) Preload(data interface{}, fields ...string) error {
+	return preload(context.Background(), tx, data, fields...)
+}
+
+func (tx *Tx) PreloadContext(ctx context.Context, data interface{}, fields ...string) error {
+	return preload(ctx, tx, data, fields...)
+}
+
+func preload(ctx context.Context, xr faker, data interface{}, fields ...string) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return newError(ErrNilData, "scrud: preload nil")
+		}
+		v = v.Elem()
+	}
+
+	var elems []reflect.Value
+	switch v.Kind() {
+	case reflect.Struct:
+		if !v.CanAddr() {
+			return newError(ErrInvalidData, "scrud: preload need pointer")
+		}
+		elems = append(elems, v)
+	case reflect.Slice, reflect.Array:
+		for i, n := 0, v.Len(); i < n; i++ {
+			w := v.Index(i)
+			if w.Kind() == reflect.Ptr {
+				if w.IsNil() {
+					continue
+				}
+				w = w.Elem()
+			}
+			if w.Kind() != reflect.Struct || !w.CanAddr() {
+				return newError(ErrInvalidData, "scrud: preload need pointer or slice of struct")
+			}
+			elems = append(elems, w)
+		}
+	default:
+		return newError(ErrInvalidData, "scrud: preload need struct or slice of struct")
+	}
+
+	t := v.Type()
+	if t.Kind() != reflect.Struct {
+		if t = t.Elem(); t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	x, err := table.TableOf(t)
+	if err != nil {
+		return err
+	}
+
+	return preloadFields(ctx, xr, x, elems, fields)
+}
+
+// group the nested fields by the first field
+func preloadFields(ctx context.Context, xr faker, x *table.Table, elems []reflect.Value, fields []string) error {
+	var heads []string
+	nested := make(map[string][]string)
+	for _, f := range fields {
+		head, rest := f, ""
+		if i := strings.Index(f, "."); i != -1 {
+			head, rest = f[:i], f[i+1:]
+		}
+		if _, ok := nested[head]; !ok {
+			heads = append(heads, head)
+			nested[head] = nil
+		}
+		if rest != "" {
+			nested[head] = append(nested[head], rest)
+		}
+	}
+
+	for _, head := range heads {
+		c := x.FindField(head)
+		if c == nil {
+			return &ColumnNotFoundError{"preload", x.Type.Name(), head}
+		}
+		var err error
+		switch c.Relation {
+		case table.OneToOne, table.ManyToOne:
+			err = preloadOne(ctx, xr, c, elems, nested[head])
+		case table.OneToMany:
+			err = preloadOneToMany(ctx, xr, c, elems, nested[head])
+		case table.ManyToMany:
+			err = preloadManyToMany(ctx, xr, c, elems, nested[head])
+		default:
+			err = newError(ErrNotRelation, "scrud: preload column no relation: "+c.FullName())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func preloadOne(ctx context.Context, xr faker, c *table.Column, elems []reflect.Value, nested []string) error {
+	cols := c.FieldColumns()
+	refs := make([]*table.Column, len(cols))
+	for k, fc := range cols {
+		refs[k] = fc.RelationKey
+	}
+
+	keys := make([][]interface{}, len(elems))
+	for i, v := range elems {
+		k, err := columnValues(cols, v)
+		if err != nil {
+			return err
+		}
+		if k[0] != nil { // nil pointer
+			keys[i] = k
+		}
+	}
+
+	rt := c.RelationTable
+	rows, err := preloadIn(ctx, xr, rt, columnNames(refs, ""), keys)
+	if err != nil {
+		return err
+	}
+	if err := preloadNested(ctx, xr, rt, rows, nested); err != nil {
+		return err
+	}
+
+	m := make(map[interface{}]reflect.Value, len(rows))
+	for _, w := range rows {
+		k, err := columnValues(refs, w)
+		if err != nil {
+			return err
+		}
+		m[preloadTuple(k)] = w
+	}
+
+	for i, v := range elems {
+		if keys[i] == nil {
+			continue
+		}
+		w, ok := m[preloadTuple(keys[i])]
+		if !ok {
+			continue // soft deleted or not exists
+		}
+		if f := c.FieldValue(v); f.Kind() == reflect.Ptr {
+			f.Set(w.Addr())
+		} else {
+			f.Set(w)
+		}
+	}
+
+	return nil
+}
+
+func preloadOneToMany(ctx context.Context, xr faker, c *table.Column, elems []reflect.Value, nested []string) error {
+	rt := c.RelationTable
+	fks := make([]*table.Column, len(c.NameLeft))
+	for k, name := range c.NameLeft {
+		if fks[k] = rt.ColumnMap[name]; fks[k] == nil {
+			return &ColumnNotFoundError{"preload", rt.Type.Name(), name}
+		}
+	}
+
+	keys, err := preloadKeys(c.Table, elems)
+	if err != nil {
+		return err
+	}
+
+	rows, err := preloadIn(ctx, xr, rt, c.NameLeft, keys)
+	if err != nil {
+		return err
+	}
+	if err := preloadNested(ctx, xr, rt, rows, nested); err != nil {
+		return err
+	}
+
+	m := make(map[interface{}][]reflect.Value)
+	for _, w := range rows {
+		k, err := columnValues(fks, w)
+		if err != nil {
+			return err
+		}
+		t := preloadTuple(k)
+		m[t] = append(m[t], w)
+	}
+
+	for i, v := range elems {
+		preloadSet(c.FieldValue(v), m[preloadTuple(keys[i])])
+	}
+
+	return nil
+}
+
+func preloadManyToMany(ctx context.Context, xr faker, c *table.Column, elems []reflect.Value, nested []string) error {
+	keys, err := preloadKeys(c.Table, elems)
+	if err != nil {
+		return err
+	}
+
+	rt := c.RelationTable
+	name, left, right := joinColumns(c)
+	elect := make([]interface{}, 0, len(left)+len(right))
+	types := make([]reflect.Type, 0, len(left)+len(right))
+	for k, pk := range c.Table.PrimaryKeys {
+		elect = append(elect, left[k])
+		types = append(types, keyType(pk))
+	}
+	for k, pk := range rt.PrimaryKeys {
+		elect = append(elect, right[k])
+		types = append(types, keyType(pk))
+	}
+
+	pairs := make(map[interface{}][][]interface{})
+	var rights [][]interface{}
+	for _, a := range preloadChunk(xr, keys) {
+		where := keysCond(left, a)
+		if c.ThroughTable != nil {
+			where = scope(xr, c.ThroughTable, where)
+		}
+		r := fetch(ctx, xr, Select(elect...).From(name).Where(where))
+		if r.err != nil {
+			return r.err
+		}
+		for r.Next() {
+			scans := make([]interface{}, len(types))
+			for k, t := range types {
+				scans[k] = reflect.New(t).Interface()
+			}
+			if err := r.Rows.Scan(scans...); err != nil {
+				r.Close()
+				return err
+			}
+			for k, p := range scans {
+				scans[k] = reflect.ValueOf(p).Elem().Interface()
+			}
+			k := preloadTuple(scans[:len(left)])
+			pairs[k] = append(pairs[k], scans[len(left):])
+			rights = append(rights, scans[len(left):])
+		}
+		err := r.Err()
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err := preloadIn(ctx, xr, rt, columnNames(rt.PrimaryKeys, ""), rights)
+	if err != nil {
+		return err
+	}
+	if err := preloadNested(ctx, xr, rt, rows, nested); err != nil {
+		return err
+	}
+
+	m := make(map[interface{}]reflect.Value, len(rows))
+	for _, w := range rows {
+		k, err := pkValues("preload", rt, w)
+		if err != nil {
+			return err
+		}
+		m[preloadTuple(k)] = w
+	}
+
+	for i, v := range elems {
+		var a []reflect.Value
+		for _, k := range pairs[preloadTuple(keys[i])] {
+			if w, ok := m[preloadTuple(k)]; ok {
+				a = append(a, w)
+			}
+		}
+		preloadSet(c.FieldValue(v), a)
+	}
+
+	return nil
+}
+
+func preloadNested(ctx context.Context, xr faker, x *table.Table, rows []reflect.Value, nested []string) error {
+	if len(nested) == 0 || len(rows) == 0 {
+		return nil
+	}
+	return preloadFields(ctx, xr, x, rows, nested)
+}
+
+func preloadKeys(x *table.Table, elems []reflect.Value) ([][]interface{}, error) {
+	keys := make([][]interface{}, len(elems))
+	for i, v := range elems {
+		k, err := pkValues("preload", x, v)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+// the scan type of the primary key, the primary key of the relation if one relation
+func keyType(c *table.Column) reflect.Type {
+	for c.IsOneRelation() {
+		c = c.RelationKey
+	}
+	return c.Type
+}
+
+// select the rows of the table that the columns in the distinct keys, chunked by max placeholders
+func preloadIn(ctx context.Context, xr faker, x *table.Table, names []string, keys [][]interface{}) ([]reflect.Value, error) {
+	seen := make(map[interface{}]struct{}, len(keys))
+	distinct := make([][]interface{}, 0, len(keys))
+	for _, k := range keys {
+		if k == nil {
+			continue
+		}
+		if _, ok := seen[preloadTuple(k)]; !ok {
+			seen[preloadTuple(k)] = struct{}{}
+			distinct = append(distinct, k)
+		}
+	}
+	if len(distinct) == 0 {
+		return nil, nil
+	}
+
+	elect := make([]interface{}, 0, len(x.ColumnMap))
+	for _, c := range x.Columns {
+		if !c.IsManyRelation() {
+			elect = append(elect, c.Name)
+		}
+	}
+
+	var rows []reflect.Value
+	for _, a := range preloadChunk(xr, distinct) {
+		p := reflect.New(reflect.SliceOf(reflect.PtrTo(x.Type)))
+		if err := fetch(ctx, xr, Select(elect...).From(x.Name).Where(scope(xr, x, keysCond(names, a)))).All(p.Interface()); err != nil {
+			return nil, err
+		}
+		for i, s := 0, p.Elem(); i < s.Len(); i++ {
+			rows = append(rows, s.Index(i).Elem())
+		}
+	}
+	return rows, nil
+}
+
+// a chunk has at most max placeholders of the key columns
+func preloadChunk(xr faker, keys [][]interface{}) [][][]interface{} {
+	size := xr.options().MaxPlaceholders
+	if size <= 0 {
+		size = maxPlaceholders[xr.Starter().DriverName()]
+	}
+	if len(keys) > 0 {
+		size /= len(keys[0])
+	}
+	if size <= 0 {
+		size = len(keys)
+	}
+	var a [][][]interface{}
+	for lo := 0; lo < len(keys); lo += size {
+		hi := lo + size
+		if hi > len(keys) {
+			hi = len(keys)
+		}
+		a = append(a, keys[lo:hi])
+	}
+	return a
+}
+
+// set the many relation field, support slice of struct or *struct and pointer to the slice
+func preloadSet(f reflect.Value, a []reflect.Value) {
+	t := f.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := reflect.MakeSlice(t, 0, len(a))
+	ptr := t.Elem().Kind() == reflect.Ptr
+	for _, w := range a {
+		if ptr {
+			s = reflect.Append(s, w.Addr())
+		} else {
+			s = reflect.Append(s, w)
+		}
+	}
+	if f.Kind() == reflect.Ptr {
+		p := reflect.New(t)
+		p.Elem().Set(s)
+		f.Set(p)
+	} else {
+		f.Set(s)
+	}
+}
+
+// normalize the key as map key, ints and uints to int64, strings and bytes to string
+func preloadKey(k interface{}) interface{} {
+	v := reflect.ValueOf(k)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.String:
+		return v.String()
+	case reflect.Slice:
+		if b, ok := k.([]byte); ok {
+			return string(b)
+		}
+	}
+	return k
+}
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// normalize the values of the columns as map key, the array of the normalized values if more than one
+func preloadTuple(k []interface{}) interface{} {
+	if len(k) == 1 {
+		return preloadKey(k[0])
+	}
+	a := reflect.New(reflect.ArrayOf(len(k), interfaceType)).Elem()
+	for i, j := range k {
+		if j = preloadKey(j); j != nil {
+			a.Index(i).Set(reflect.ValueOf(j))
+		}
+	}
+	return a.Interface()
+}
@@ -0,0 +1,154 @@
+// Copyright 2015 Chen Xianren. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package query
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+type compound struct {
+	op            string
+	members       []Expression
+	order         []interface{}
+	limit, offset int
+}
+
+// select UNION select ...
+func Union(a ...Expression) *compound {
+	return &compound{op: "UNION", members: a}
+}
+
+// select UNION ALL select ...
+func UnionAll(a ...Expression) *compound {
+	return &compound{op: "UNION ALL", members: a}
+}
+
+// select INTERSECT select ..., not for mysql
+func Intersect(a ...Expression) *compound {
+	return &compound{op: "INTERSECT", members: a}
+}
+
+// select EXCEPT select ..., not for mysql
+func Except(a ...Expression) *compound {
+	return &compound{op: "EXCEPT", members: a}
+}
+
+func (c *compound) Err() error {
+	return nil
+}
+
+// members are parenthesized, sqlite disallow it so that
+// a nested compound or a select with order by or limit is wrapped as SELECT * FROM (...),
+// and only the first select can have a with clause
+func (c *compound) Expand(s Starter) (string, []interface{}, error) {
+	if len(c.members) < 2 {
+		return "", nil, errors.New("compound: need two or more members")
+	}
+	if s.DriverName() == "mysql" && (c.op == "INTERSECT" || c.op == "EXCEPT") {
+		return "", nil, errors.New("compound: " + c.op + " not supported by mysql")
+	}
+
+	buf, args := new(bytes.Buffer), make([]interface{}, 0)
+
+	sqlite := s.DriverName() == "sqlite"
+	for k, v := range c.members {
+		if v == nil {
+			return "", nil, errors.New("compound: nil member")
+		}
+		if r, ok := v.(*retrieve); ok && sqlite && k > 0 && r.with != nil {
+			return "", nil, errors.New("compound: with after the first select not supported by sqlite")
+		}
+		e, a, err := v.Expand(s)
+		if err != nil {
+			return "", nil, err
+		}
+		if k > 0 {
+			buf.WriteByte(' ')
+			buf.WriteString(c.op)
+			buf.WriteByte(' ')
+		}
+		if !sqlite {
+			buf.WriteByte('(')
+			buf.WriteString(e)
+			buf.WriteByte(')')
+		} else if sqliteWrap(v, k == 0) {
+			buf.WriteString("SELECT * FROM (")
+			buf.WriteString(e)
+			buf.WriteByte(')')
+		} else {
+			buf.WriteString(e)
+		}
+		args = append(args, a...)
+	}
+
+	if n := len(c.order); n > 0 {
+		buf.WriteString(" ORDER BY ")
+		for k, v := range c.order {
+			var x Expression
+			switch i := v.(type) {
+			case string:
+				x = Expr(BackQuote(i))
+			case Expression:
+				x = i
+			default:
+				return "", nil, errors.New("compound: order by must be string or expression")
+			}
+			e, a, err := x.Expand(s)
+			if err != nil {
+				return "", nil, err
+			}
+			buf.WriteString(e)
+			args = append(args, a...)
+			if k < n-1 {
+				buf.WriteByte(',')
+			}
+		}
+	}
+
+	if c.limit > 0 {
+		buf.WriteString(" LIMIT ")
+		buf.WriteString(strconv.Itoa(c.limit))
+	}
+
+	if c.offset > 0 {
+		if c.limit <= 0 {
+			buf.WriteString(" LIMIT 2147483647")
+		}
+
+		buf.WriteString(" OFFSET ")
+		buf.WriteString(strconv.Itoa(c.offset))
+	}
+
+	return buf.String(), args, nil
+}
+
+// the first nested compound keeps the meaning without parentheses since sqlite evaluate left to right
+func sqliteWrap(x Expression, first bool) bool {
+	switch i := x.(type) {
+	case *compound:
+		return !first || len(i.order) > 0 || i.limit > 0 || i.offset > 0
+	case *retrieve:
+		return len(i.order) > 0 || i.limit > 0 || i.offset > 0
+	}
+	return false
+}
+
+// string or expression
+func (c *compound) OrderBy(a ...interface{}) *compound {
+	c.order = append(c.order, a...)
+	return c
+}
+
+func (c *compound) Limit(n int) *compound {
+	c.limit = n
+	return c
+}
+
+func (c *compound) Offset(n int) *compound {
+	c.offset = n
+	return c
+}
@@ -9,11 +9,21 @@ import (
 	"errors"
 )
 
+const (
+	doUpdate = iota + 1
+	doNothing
+)
+
 type create struct {
-	alias   string
-	table   string
-	columns []string
-	values  [][]interface{}
+	alias    string
+	table    string
+	columns  []string
+	values   [][]interface{}
+	conflict []string
+	action   int
+	update   []string
+	returns  []interface{}
+	with     *with
 }
 
 // insert clause generator
@@ -36,6 +46,18 @@ func (c *create) Expand(s Starter) (string, []interface{}, error) {
 
 	buf, args := new(bytes.Buffer), make([]interface{}, 0, x*y)
 
+	if c.with != nil {
+		if s.DriverName() == "mysql" {
+			return "", nil, errors.New("create: with not supported by mysql")
+		}
+		e, a, err := c.with.expand(s, "create")
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(e)
+		args = append(args, a...)
+	}
+
 	buf.WriteString("INSERT INTO ")
 	buf.WriteString(s.FormatName(c.table))
 	buf.WriteString(" (")
@@ -67,9 +89,102 @@ func (c *create) Expand(s Starter) (string, []interface{}, error) {
 		args = append(args, v...)
 	}
 
+	if c.conflict != nil || c.action != 0 {
+		if err := c.upsert(s, buf); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if len(c.returns) > 0 {
+		e, a, err := returning(s, "create", c.returns)
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(e)
+		args = append(args, a...)
+	}
+
 	return buf.String(), args, nil
 }
 
+func (c *create) upsert(s Starter, buf *bytes.Buffer) error {
+	action, update := c.action, c.update
+	if action == doUpdate && len(update) == 0 {
+		m := make(map[string]struct{}, len(c.conflict))
+		for _, v := range c.conflict {
+			m[v] = struct{}{}
+		}
+		for _, v := range c.columns {
+			if _, ok := m[v]; !ok {
+				update = append(update, v)
+			}
+		}
+		if len(update) == 0 {
+			action = doNothing
+		}
+	}
+
+	switch action {
+	case doUpdate, doNothing:
+	default:
+		return errors.New("create: on conflict need do update or do nothing")
+	}
+
+	if s.DriverName() == "mysql" {
+		buf.WriteString(" ON DUPLICATE KEY UPDATE ")
+		if action == doNothing {
+			k := c.columns[0]
+			if len(c.conflict) > 0 {
+				k = c.conflict[0]
+			}
+			buf.WriteString(s.FormatName(k))
+			buf.WriteByte('=')
+			buf.WriteString(s.FormatName(k))
+			return nil
+		}
+		for k, v := range update {
+			buf.WriteString(s.FormatName(v))
+			buf.WriteString("=VALUES(")
+			buf.WriteString(s.FormatName(v))
+			buf.WriteByte(')')
+			if k < len(update)-1 {
+				buf.WriteByte(',')
+			}
+		}
+		return nil
+	}
+
+	buf.WriteString(" ON CONFLICT")
+	if n := len(c.conflict); n > 0 {
+		buf.WriteString(" (")
+		for k, v := range c.conflict {
+			buf.WriteString(s.FormatName(v))
+			if k < n-1 {
+				buf.WriteByte(',')
+			}
+		}
+		buf.WriteByte(')')
+	} else if action == doUpdate {
+		return errors.New("create: on conflict do update need conflict columns")
+	}
+
+	if action == doNothing {
+		buf.WriteString(" DO NOTHING")
+		return nil
+	}
+
+	buf.WriteString(" DO UPDATE SET ")
+	for k, v := range update {
+		buf.WriteString(s.FormatName(v))
+		buf.WriteString("=EXCLUDED.")
+		buf.WriteString(s.FormatName(v))
+		if k < len(update)-1 {
+			buf.WriteByte(',')
+		}
+	}
+	return nil
+}
+
 func (c *create) Columns(a ...string) *create {
 	c.columns = append(c.columns, a...)
 	return c
@@ -79,3 +194,33 @@ func (c *create) Values(a ...interface{}) *create {
 	c.values = append(c.values, a)
 	return c
 }
+
+// conflict target columns, mysql ignore it and use any unique key
+func (c *create) OnConflict(a ...string) *create {
+	c.conflict = append(c.conflict, a...)
+	return c
+}
+
+// on conflict update the columns to the inserted values, empty is all columns except conflict target
+//
+// mysql ON DUPLICATE KEY UPDATE `c`=VALUES(`c`), postgres and sqlite ON CONFLICT (...) DO UPDATE SET `c`=EXCLUDED.`c`
+func (c *create) DoUpdate(a ...string) *create {
+	c.action = doUpdate
+	c.update = append(c.update, a...)
+	return c
+}
+
+// on conflict do nothing
+//
+// mysql ON DUPLICATE KEY UPDATE `c`=`c`, postgres and sqlite ON CONFLICT ... DO NOTHING
+func (c *create) DoNothing() *create {
+	c.action = doNothing
+	c.update = nil
+	return c
+}
+
+// string or expression, mysql not supported
+func (c *create) Returning(a ...interface{}) *create {
+	c.returns = append(c.returns, a...)
+	return c
+}
@@ -11,11 +11,13 @@ import (
 )
 
 type delete struct {
-	alias string
-	table string
-	where []Condition
-	order []interface{}
-	limit int
+	alias   string
+	table   string
+	where   []Condition
+	order   []interface{}
+	limit   int
+	returns []interface{}
+	with    *with
 }
 
 // delete clause generator
@@ -30,6 +32,15 @@ func (d *delete) Err() error {
 func (d *delete) Expand(s Starter) (string, []interface{}, error) {
 	buf, args := new(bytes.Buffer), make([]interface{}, 0)
 
+	if d.with != nil {
+		e, a, err := d.with.expand(s, "delete")
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(e)
+		args = append(args, a...)
+	}
+
 	buf.WriteString("DELETE FROM ")
 	buf.WriteString(s.FormatName(d.table))
 
@@ -43,6 +54,15 @@ func (d *delete) Expand(s Starter) (string, []interface{}, error) {
 		args = append(args, a...)
 	}
 
+	if len(d.returns) > 0 {
+		e, a, err := returning(s, "delete", d.returns)
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(e)
+		args = append(args, a...)
+	}
+
 	if n := len(d.order); n > 0 {
 		buf.WriteString(" ORDER BY ")
 		for k, v := range d.order {
@@ -87,3 +107,9 @@ func (d *delete) Limit(n int) *delete {
 	d.limit = n
 	return d
 }
+
+// string or expression, mysql not supported
+func (d *delete) Returning(a ...interface{}) *delete {
+	d.returns = append(d.returns, a...)
+	return d
+}
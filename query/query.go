@@ -35,6 +35,7 @@
 //  	In("Column5", 2, 3, 4),    // `Column5` IN (?,?,?) -- 2,3,4
 //  	Contains("Column6", "_"), // `Column6` LIKE ? -- %\_%
 //  	Cond("`Table3.Column3` > `a.Column4`"),
+//  	InSelect("Column1", Select(...).From(...)), // `Column1` IN (SELECT ...) -- Exists, NotExists, NotIn, NotInSelect, AnyOf and AllOf also ok
 //  	...
 //  ).GroupBy(
 //  	"Column1",
@@ -46,6 +47,11 @@
 //  	Expr("EXTRACT(YEAR FROM Column7`)"), // -- expression also ok
 //  ).Limit(5).Offset(6).Expand(new(Postgres))
 //
+//  // Row locking, sqlite ignored
+//  query, args, err = Select(...).From("Table1").Where(
+//  	...
+//  ).ForUpdate().Of("Table1").SkipLocked().Expand(...) // FOR UPDATE OF `Table1` SKIP LOCKED -- ForShare and NoWait also ok
+//
 //  // Update
 //  query, args, err = Update("Table1").Set(
 //  	"Column1", true,
@@ -64,10 +70,30 @@
 //  	... // -- same as Retrieve OrderBy
 //  ).Limit(...).Expand(...)
 //
+//  // Common table expressions
+//  query, args, err = With( // WITH `Cte1` (`Column1`) AS (SELECT ...)
+//  	"Cte1", Select(...).From(...), "Column1",
+//  ).WithRecursive( // -- WITH RECURSIVE when any recursive
+//  	...
+//  ).Select(...).From("Cte1").Expand(...) // -- Update, Delete or Insert also ok, mysql insert not supported
+//
+//  // Set operations
+//  query, args, err = Union( // (SELECT ...) UNION (SELECT ...) -- UnionAll, Intersect and Except also ok
+//  	Select(...).From(...),
+//  	Except(...), // -- nested, sqlite as SELECT * FROM (...)
+//  ).OrderBy(...).Limit(...).Offset(...).Expand(...)
+//
+//  // Window functions
+//  query, args, err = Select(
+//  	RowNumber(Over().PartitionBy("Column1").OrderBy(Desc("Column2"))), // ROW_NUMBER() OVER (PARTITION BY `Column1` ORDER BY `Column2` DESC)
+//  	Sum("Column3", OverWindow("w").Rows("UNBOUNDED PRECEDING", "CURRENT ROW")), // -- Rank, DenseRank, Lag and Lead also ok
+//  ).From("Table1").Window("w", Over().PartitionBy(...)).Expand(...) // WINDOW `w` AS (PARTITION BY ...)
+//
 // See https://github.com/cxr29/scrud for more details
 package query
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 )
@@ -212,6 +238,63 @@ func IsNull(k string) Condition {
 	return Cond(BackQuote(k) + " IS NULL")
 }
 
+// EXISTS (subquery)
+func Exists(x Expression) Condition {
+	return Cond("EXISTS (?)", x)
+}
+
+// NOT EXISTS (subquery)
+func NotExists(x Expression) Condition {
+	return Cond("NOT EXISTS (?)", x)
+}
+
+// `k` IN (subquery)
+func InSelect(k string, x Expression) Condition {
+	return Cond(BackQuote(k)+" IN (?)", x)
+}
+
+// `k` NOT IN (?,...)
+func NotIn(k string, a ...interface{}) Condition {
+	return Cond(BackQuote(k)+" NOT IN ("+RepeatMarker(len(a))+")", a...)
+}
+
+// `k` NOT IN (subquery)
+func NotInSelect(k string, x Expression) Condition {
+	return Cond(BackQuote(k)+" NOT IN (?)", x)
+}
+
+// `k` op ANY (subquery), op is one of =, <>, !=, <, <=, >, >=, sqlite not supported
+func AnyOf(k, op string, x Expression) Condition {
+	return quantified("any of", k, op, "ANY", x)
+}
+
+// `k` op ALL (subquery), op is one of =, <>, !=, <, <=, >, >=, sqlite not supported
+func AllOf(k, op string, x Expression) Condition {
+	return quantified("all of", k, op, "ALL", x)
+}
+
+func quantified(clause, k, op, q string, x Expression) Condition {
+	switch op {
+	case "=", "<>", "!=", "<", "<=", ">", ">=":
+	default:
+		return &cond{expr: errExpr(clause + ": invalid operator " + op)}
+	}
+	return Cond(BackQuote(k)+op+" "+q+" (?)", noSqlite{clause, x})
+}
+
+// expression not supported by sqlite
+type noSqlite struct {
+	clause string
+	Expression
+}
+
+func (n noSqlite) Expand(s Starter) (string, []interface{}, error) {
+	if s.DriverName() == "sqlite" {
+		return "", nil, errors.New(n.clause + ": not supported by sqlite")
+	}
+	return n.Expression.Expand(s)
+}
+
 // `k` ASC
 func Asc(k string) Expression {
 	return Expr(BackQuote(k) + " ASC")
@@ -41,6 +41,56 @@ func TestCreate(t *testing.T) {
 	}
 }
 
+func TestUpsert(t *testing.T) {
+	for _, s := range []Starter{
+		new(MySQL),
+		new(Postgres),
+		new(Sqlite),
+	} {
+		q, a, err := Insert("t1").Columns(
+			"c1", "c2", "c3",
+		).Values(
+			1, 2, "v1",
+		).OnConflict("c1").DoUpdate().Expand(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != map[string]string{
+			"mysql":    "INSERT INTO `t1` (`c1`,`c2`,`c3`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `c2`=VALUES(`c2`),`c3`=VALUES(`c3`)",
+			"postgres": `INSERT INTO "t1" ("c1","c2","c3") VALUES ($1,$2,$3) ON CONFLICT ("c1") DO UPDATE SET "c2"=EXCLUDED."c2","c3"=EXCLUDED."c3"`,
+			"sqlite":   `INSERT INTO "t1" ("c1","c2","c3") VALUES (?,?,?) ON CONFLICT ("c1") DO UPDATE SET "c2"=EXCLUDED."c2","c3"=EXCLUDED."c3"`,
+		}[s.DriverName()] {
+			t.Fatal(s.DriverName(), "upsert do update query", q)
+		}
+		if len(a) != 3 {
+			t.Fatal(s.DriverName(), "upsert do update argument")
+		}
+	}
+
+	for _, s := range []Starter{
+		new(MySQL),
+		new(Postgres),
+		new(Sqlite),
+	} {
+		q, _, err := Insert("t1").Columns("c1", "c2").Values(1, 2).OnConflict("c1").DoNothing().Expand(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != map[string]string{
+			"mysql":    "INSERT INTO `t1` (`c1`,`c2`) VALUES (?,?) ON DUPLICATE KEY UPDATE `c1`=`c1`",
+			"postgres": `INSERT INTO "t1" ("c1","c2") VALUES ($1,$2) ON CONFLICT ("c1") DO NOTHING`,
+			"sqlite":   `INSERT INTO "t1" ("c1","c2") VALUES (?,?) ON CONFLICT ("c1") DO NOTHING`,
+		}[s.DriverName()] {
+			t.Fatal(s.DriverName(), "upsert do nothing query", q)
+		}
+
+		_, _, err = Insert("t1").Columns("c1").Values(1).DoUpdate("c1").Expand(s)
+		if (err == nil) != (s.DriverName() == "mysql") {
+			t.Fatal(s.DriverName(), "upsert do update without conflict columns")
+		}
+	}
+}
+
 func TestRetrieve(t *testing.T) {
 	for _, s := range []Starter{
 		new(MySQL),
@@ -153,3 +203,278 @@ func TestDelete(t *testing.T) {
 		}
 	}
 }
+
+func TestReturning(t *testing.T) {
+	for _, s := range []Starter{
+		new(MySQL),
+		new(Postgres),
+		new(Sqlite),
+	} {
+		a := []Expression{
+			Insert("t1").Columns("c1").Values(1).Returning("id"),
+			Update("t1").Set("c1", 2).Where(Eq("id", 3)).Returning("id", Expr("`c1`+?", 4)),
+			Delete("t1").Where(Eq("id", 5)).Returning("c1"),
+		}
+		b := map[string][]string{
+			"postgres": {
+				`INSERT INTO "t1" ("c1") VALUES ($1) RETURNING "id"`,
+				`UPDATE "t1" SET "c1"=$1 WHERE "id"=$2 RETURNING "id","c1"+$3`,
+				`DELETE FROM "t1" WHERE "id"=$1 RETURNING "c1"`,
+			},
+			"sqlite": {
+				`INSERT INTO "t1" ("c1") VALUES (?) RETURNING "id"`,
+				`UPDATE "t1" SET "c1"=? WHERE "id"=? RETURNING "id","c1"+?`,
+				`DELETE FROM "t1" WHERE "id"=? RETURNING "c1"`,
+			},
+		}[s.DriverName()]
+		for k, e := range a {
+			if p, ok := s.(*Postgres); ok {
+				*p = 0
+			}
+			q, _, err := e.Expand(s)
+			if s.DriverName() == "mysql" {
+				if err == nil {
+					t.Fatal(s.DriverName(), "returning not supported")
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q != b[k] {
+				t.Fatal(s.DriverName(), "returning query", q)
+			}
+		}
+	}
+}
+
+func TestWith(t *testing.T) {
+	for _, s := range []Starter{
+		new(MySQL),
+		new(Postgres),
+		new(Sqlite),
+	} {
+		w := WithRecursive("t2", Select(Expr("?", 1)).From("t1").Where(Gt("c1", 2)), "c2").
+			With("t3", Select("c3").From("t1"))
+		a := []Expression{
+			w.Select("c2").From("t2").Where(Lt("c2", 3)),
+			w.Update("t1").Set("c1", 4).Where(Cond("`c1` IN (SELECT `c2` FROM `t2`)")),
+			w.Delete("t1").Where(Eq("c1", 5)),
+			w.Insert("t1").Columns("c1").Values(6),
+		}
+		b := map[string][]string{
+			"mysql": {
+				"WITH RECURSIVE `t2` (`c2`) AS (SELECT ? FROM `t1` WHERE `c1`>?),`t3` AS (SELECT `c3` FROM `t1`) SELECT `c2` FROM `t2` WHERE `c2`<?",
+				"WITH RECURSIVE `t2` (`c2`) AS (SELECT ? FROM `t1` WHERE `c1`>?),`t3` AS (SELECT `c3` FROM `t1`) UPDATE `t1` SET `c1`=? WHERE `c1` IN (SELECT `c2` FROM `t2`)",
+				"WITH RECURSIVE `t2` (`c2`) AS (SELECT ? FROM `t1` WHERE `c1`>?),`t3` AS (SELECT `c3` FROM `t1`) DELETE FROM `t1` WHERE `c1`=?",
+			},
+			"postgres": {
+				`WITH RECURSIVE "t2" ("c2") AS (SELECT $1 FROM "t1" WHERE "c1">$2),"t3" AS (SELECT "c3" FROM "t1") SELECT "c2" FROM "t2" WHERE "c2"<$3`,
+				`WITH RECURSIVE "t2" ("c2") AS (SELECT $1 FROM "t1" WHERE "c1">$2),"t3" AS (SELECT "c3" FROM "t1") UPDATE "t1" SET "c1"=$3 WHERE "c1" IN (SELECT "c2" FROM "t2")`,
+				`WITH RECURSIVE "t2" ("c2") AS (SELECT $1 FROM "t1" WHERE "c1">$2),"t3" AS (SELECT "c3" FROM "t1") DELETE FROM "t1" WHERE "c1"=$3`,
+				`WITH RECURSIVE "t2" ("c2") AS (SELECT $1 FROM "t1" WHERE "c1">$2),"t3" AS (SELECT "c3" FROM "t1") INSERT INTO "t1" ("c1") VALUES ($3)`,
+			},
+			"sqlite": {
+				`WITH RECURSIVE "t2" ("c2") AS (SELECT ? FROM "t1" WHERE "c1">?),"t3" AS (SELECT "c3" FROM "t1") SELECT "c2" FROM "t2" WHERE "c2"<?`,
+				`WITH RECURSIVE "t2" ("c2") AS (SELECT ? FROM "t1" WHERE "c1">?),"t3" AS (SELECT "c3" FROM "t1") UPDATE "t1" SET "c1"=? WHERE "c1" IN (SELECT "c2" FROM "t2")`,
+				`WITH RECURSIVE "t2" ("c2") AS (SELECT ? FROM "t1" WHERE "c1">?),"t3" AS (SELECT "c3" FROM "t1") DELETE FROM "t1" WHERE "c1"=?`,
+				`WITH RECURSIVE "t2" ("c2") AS (SELECT ? FROM "t1" WHERE "c1">?),"t3" AS (SELECT "c3" FROM "t1") INSERT INTO "t1" ("c1") VALUES (?)`,
+			},
+		}[s.DriverName()]
+		for k, e := range a {
+			if p, ok := s.(*Postgres); ok {
+				*p = 0
+			}
+			q, args, err := e.Expand(s)
+			if k >= len(b) {
+				if err == nil {
+					t.Fatal(s.DriverName(), "with insert not supported")
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q != b[k] {
+				t.Fatal(s.DriverName(), "with query", q)
+			}
+			if len(args) != 3 ||
+				args[0].(int) != 1 ||
+				args[1].(int) != 2 ||
+				args[2].(int) != 3+k {
+				t.Fatal(s.DriverName(), "with argument")
+			}
+		}
+	}
+}
+
+func TestCompound(t *testing.T) {
+	for _, s := range []Starter{
+		new(MySQL),
+		new(Postgres),
+		new(Sqlite),
+	} {
+		except := Except
+		if s.DriverName() == "mysql" {
+			except = Union
+		}
+		q, a, err := Union(
+			Select("c1").From("t1").Where(Eq("c2", 1)),
+			except(Select("c1").From("t2"), Select("c1").From("t3").Where(Eq("c2", 2))),
+			UnionAll(Select("c1").From("t4"), Select("c1").From("t5")).Limit(3),
+		).OrderBy(Desc("c1")).Limit(4).Offset(5).Expand(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != map[string]string{
+			"mysql":    "(SELECT `c1` FROM `t1` WHERE `c2`=?) UNION ((SELECT `c1` FROM `t2`) UNION (SELECT `c1` FROM `t3` WHERE `c2`=?)) UNION ((SELECT `c1` FROM `t4`) UNION ALL (SELECT `c1` FROM `t5`) LIMIT 3) ORDER BY `c1` DESC LIMIT 4 OFFSET 5",
+			"postgres": `(SELECT "c1" FROM "t1" WHERE "c2"=$1) UNION ((SELECT "c1" FROM "t2") EXCEPT (SELECT "c1" FROM "t3" WHERE "c2"=$2)) UNION ((SELECT "c1" FROM "t4") UNION ALL (SELECT "c1" FROM "t5") LIMIT 3) ORDER BY "c1" DESC LIMIT 4 OFFSET 5`,
+			"sqlite":   `SELECT "c1" FROM "t1" WHERE "c2"=? UNION SELECT * FROM (SELECT "c1" FROM "t2" EXCEPT SELECT "c1" FROM "t3" WHERE "c2"=?) UNION SELECT * FROM (SELECT "c1" FROM "t4" UNION ALL SELECT "c1" FROM "t5" LIMIT 3) ORDER BY "c1" DESC LIMIT 4 OFFSET 5`,
+		}[s.DriverName()] {
+			t.Fatal(s.DriverName(), "compound query", q)
+		}
+		if len(a) != 2 ||
+			a[0].(int) != 1 ||
+			a[1].(int) != 2 {
+			t.Fatal(s.DriverName(), "compound argument")
+		}
+	}
+
+	if _, _, err := Union(Select().From("t1")).Expand(new(MySQL)); err == nil {
+		t.Fatal("compound need two or more members")
+	}
+	if _, _, err := Intersect(Select().From("t1"), Select().From("t2")).Expand(new(MySQL)); err == nil {
+		t.Fatal("compound mysql intersect")
+	}
+	w := With("t", Select().From("t1"))
+	if q, _, err := Union(w.Select().From("t"), Select().From("t2")).Expand(new(Sqlite)); err != nil ||
+		q != `WITH "t" AS (SELECT * FROM "t1") SELECT * FROM "t" UNION SELECT * FROM "t2"` {
+		t.Fatal(q, err)
+	}
+	if _, _, err := Union(Select().From("t2"), w.Select().From("t")).Expand(new(Sqlite)); err == nil {
+		t.Fatal("compound sqlite with")
+	}
+}
+
+func TestWindow(t *testing.T) {
+	for _, s := range []Starter{
+		new(MySQL),
+		new(Postgres),
+		new(Sqlite),
+	} {
+		q, a, err := Select(
+			"c1",
+			As(RowNumber(Over().PartitionBy("c2").OrderBy(Desc("c3"))), "n"),
+			As(Rank(OverWindow("w")), "r"),
+			As(DenseRank(nil), "d"),
+			As(Lag("c3", 1, OverWindow("w").Rows("UNBOUNDED PRECEDING", "CURRENT ROW")), "p"),
+			As(Lead("c3", 2, Over().OrderBy("t1.c1")), "q"),
+			As(Sum("c3", Over().PartitionBy(Expr("`c2`+?", 1)).Range("CURRENT ROW", "")), "s"),
+		).From("t1").Where(Gt("c1", 2)).Window("w", Over().PartitionBy("c2").OrderBy("c3")).Expand(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != map[string]string{
+			"mysql":    "SELECT `c1`,(ROW_NUMBER() OVER (PARTITION BY `c2` ORDER BY `c3` DESC)) AS `n`,(RANK() OVER `w`) AS `r`,(DENSE_RANK() OVER ()) AS `d`,(LAG(`c3`,1) OVER (`w` ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)) AS `p`,(LEAD(`c3`,2) OVER (ORDER BY `t1`.`c1`)) AS `q`,(SUM(`c3`) OVER (PARTITION BY `c2`+? RANGE CURRENT ROW)) AS `s` FROM `t1` WHERE `c1`>? WINDOW `w` AS (PARTITION BY `c2` ORDER BY `c3`)",
+			"postgres": `SELECT "c1",(ROW_NUMBER() OVER (PARTITION BY "c2" ORDER BY "c3" DESC)) AS "n",(RANK() OVER "w") AS "r",(DENSE_RANK() OVER ()) AS "d",(LAG("c3",1) OVER ("w" ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)) AS "p",(LEAD("c3",2) OVER (ORDER BY "t1"."c1")) AS "q",(SUM("c3") OVER (PARTITION BY "c2"+$1 RANGE CURRENT ROW)) AS "s" FROM "t1" WHERE "c1">$2 WINDOW "w" AS (PARTITION BY "c2" ORDER BY "c3")`,
+			"sqlite":   `SELECT "c1",(ROW_NUMBER() OVER (PARTITION BY "c2" ORDER BY "c3" DESC)) AS "n",(RANK() OVER "w") AS "r",(DENSE_RANK() OVER ()) AS "d",(LAG("c3",1) OVER ("w" ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)) AS "p",(LEAD("c3",2) OVER (ORDER BY "t1"."c1")) AS "q",(SUM("c3") OVER (PARTITION BY "c2"+? RANGE CURRENT ROW)) AS "s" FROM "t1" WHERE "c1">? WINDOW "w" AS (PARTITION BY "c2" ORDER BY "c3")`,
+		}[s.DriverName()] {
+			t.Fatal(s.DriverName(), "window query", q)
+		}
+		if len(a) != 2 ||
+			a[0].(int) != 1 ||
+			a[1].(int) != 2 {
+			t.Fatal(s.DriverName(), "window argument")
+		}
+	}
+}
+
+func TestLock(t *testing.T) {
+	for _, s := range []Starter{
+		new(MySQL),
+		new(Postgres),
+		new(Sqlite),
+	} {
+		q, a, err := Select("c1").From("t1").Where(Eq("c2", 1)).Limit(2).ForUpdate().Of("t1").SkipLocked().Expand(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != map[string]string{
+			"mysql":    "SELECT `c1` FROM `t1` WHERE `c2`=? LIMIT 2 FOR UPDATE OF `t1` SKIP LOCKED",
+			"postgres": `SELECT "c1" FROM "t1" WHERE "c2"=$1 LIMIT 2 FOR UPDATE OF "t1" SKIP LOCKED`,
+			"sqlite":   `SELECT "c1" FROM "t1" WHERE "c2"=? LIMIT 2`,
+		}[s.DriverName()] {
+			t.Fatal(s.DriverName(), "lock query", q)
+		}
+		if len(a) != 1 || a[0].(int) != 1 {
+			t.Fatal(s.DriverName(), "lock argument")
+		}
+
+		if q, _, err := Select().From("t1").ForShare().NoWait().Expand(s); err != nil {
+			t.Fatal(err)
+		} else if q != map[string]string{
+			"mysql":    "SELECT * FROM `t1` FOR SHARE NOWAIT",
+			"postgres": `SELECT * FROM "t1" FOR SHARE NOWAIT`,
+			"sqlite":   `SELECT * FROM "t1"`,
+		}[s.DriverName()] {
+			t.Fatal(s.DriverName(), "lock query", q)
+		}
+
+		if _, _, err := Select().From("t1").NoWait().Expand(s); err == nil {
+			t.Fatal(s.DriverName(), "nowait need for update")
+		}
+	}
+}
+
+func TestSubquery(t *testing.T) {
+	for _, s := range []Starter{
+		new(MySQL),
+		new(Postgres),
+		new(Sqlite),
+	} {
+		q, a, err := Select("c1").From("t1").Where(
+			Eq("c2", 1),
+			Exists(Select("c1").From("t2").Where(Cond("`t2.c1`=`t1.c1`"), Gt("c3", 2))),
+			Or(InSelect("c1", Select("c1").From("t3").Where(Eq("c4", 3))), NotIn("c1", 4, 5)),
+			NotExists(Select().From("t4")).And(NotInSelect("c5", Select("c5").From("t5"))),
+		).Expand(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != map[string]string{
+			"mysql":    "SELECT `c1` FROM `t1` WHERE (`c2`=?) AND (EXISTS (SELECT `c1` FROM `t2` WHERE (`t2`.`c1`=`t1`.`c1`) AND (`c3`>?))) AND ((`c1` IN (SELECT `c1` FROM `t3` WHERE `c4`=?)) OR (`c1` NOT IN (?,?))) AND ((NOT EXISTS (SELECT * FROM `t4`)) AND (`c5` NOT IN (SELECT `c5` FROM `t5`)))",
+			"postgres": `SELECT "c1" FROM "t1" WHERE ("c2"=$1) AND (EXISTS (SELECT "c1" FROM "t2" WHERE ("t2"."c1"="t1"."c1") AND ("c3">$2))) AND (("c1" IN (SELECT "c1" FROM "t3" WHERE "c4"=$3)) OR ("c1" NOT IN ($4,$5))) AND ((NOT EXISTS (SELECT * FROM "t4")) AND ("c5" NOT IN (SELECT "c5" FROM "t5")))`,
+			"sqlite":   `SELECT "c1" FROM "t1" WHERE ("c2"=?) AND (EXISTS (SELECT "c1" FROM "t2" WHERE ("t2"."c1"="t1"."c1") AND ("c3">?))) AND (("c1" IN (SELECT "c1" FROM "t3" WHERE "c4"=?)) OR ("c1" NOT IN (?,?))) AND ((NOT EXISTS (SELECT * FROM "t4")) AND ("c5" NOT IN (SELECT "c5" FROM "t5")))`,
+		}[s.DriverName()] {
+			t.Fatal(s.DriverName(), "subquery query", q)
+		}
+		if len(a) != 5 {
+			t.Fatal(s.DriverName(), "subquery argument")
+		}
+		for k, v := range a {
+			if v.(int) != k+1 {
+				t.Fatal(s.DriverName(), "subquery argument")
+			}
+		}
+
+		q, a, err = Select("c1").From("t1").Where(
+			AnyOf("c6", ">", Select("c6").From("t6").Where(Lt("c6", 6))).Or(AllOf("c6", "=", Select("c6").From("t7"))),
+		).Expand(s)
+		if s.DriverName() == "sqlite" {
+			if err == nil {
+				t.Fatal(s.DriverName(), "subquery any of")
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		} else if q != map[string]string{
+			"mysql":    "SELECT `c1` FROM `t1` WHERE (`c6`> ANY (SELECT `c6` FROM `t6` WHERE `c6`<?)) OR (`c6`= ALL (SELECT `c6` FROM `t7`))",
+			"postgres": `SELECT "c1" FROM "t1" WHERE ("c6"> ANY (SELECT "c6" FROM "t6" WHERE "c6"<$6)) OR ("c6"= ALL (SELECT "c6" FROM "t7"))`, // same starter keeps counting
+		}[s.DriverName()] || len(a) != 1 || a[0].(int) != 6 {
+			t.Fatal(s.DriverName(), "subquery any of", q, a)
+		}
+	}
+
+	if _, _, err := AnyOf("c1", "; DROP", Select("c1").From("t1")).Expand(new(MySQL)); err == nil {
+		t.Fatal("subquery any of operator")
+	}
+}
@@ -16,6 +16,11 @@ type join struct {
 	condtion []interface{}
 }
 
+type namedWindow struct {
+	name   string
+	window *window
+}
+
 type retrieve struct {
 	alias               string
 	join                []join
@@ -23,6 +28,10 @@ type retrieve struct {
 	from                []interface{}
 	where, having       []Condition
 	limit, offset       int
+	with                *with
+	windows             []namedWindow
+	lock, wait          string
+	lockOf              []string
 }
 
 // select clause generator
@@ -45,6 +54,15 @@ func (r *retrieve) Err() error {
 func (r *retrieve) Expand(s Starter) (string, []interface{}, error) {
 	buf, args := new(bytes.Buffer), make([]interface{}, 0)
 
+	if r.with != nil {
+		e, a, err := r.with.expand(s, "retrieve")
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(e)
+		args = append(args, a...)
+	}
+
 	buf.WriteString("SELECT ")
 
 	if n := len(r.elect); n > 0 {
@@ -195,6 +213,29 @@ func (r *retrieve) Expand(s Starter) (string, []interface{}, error) {
 		args = append(args, a...)
 	}
 
+	if n := len(r.windows); n > 0 {
+		buf.WriteString(" WINDOW ")
+		for k, v := range r.windows {
+			if v.window == nil {
+				return "", nil, errors.New("retrieve: window nil")
+			}
+			e, a, err := v.window.Expand(s)
+			if err != nil {
+				return "", nil, err
+			}
+			buf.WriteString(s.FormatName(v.name))
+			buf.WriteString(" AS ")
+			if e[0] != '(' {
+				e = "(" + e + ")"
+			}
+			buf.WriteString(e)
+			args = append(args, a...)
+			if k < n-1 {
+				buf.WriteByte(',')
+			}
+		}
+	}
+
 	if n := len(r.order); n > 0 {
 		buf.WriteString(" ORDER BY ")
 		for k, v := range r.order {
@@ -233,6 +274,27 @@ func (r *retrieve) Expand(s Starter) (string, []interface{}, error) {
 		buf.WriteString(strconv.Itoa(r.offset))
 	}
 
+	if r.lock == "" && (r.wait != "" || len(r.lockOf) > 0) {
+		return "", nil, errors.New("retrieve: skip locked, nowait and of need for update or for share")
+	}
+	if r.lock != "" && s.DriverName() != "sqlite" {
+		buf.WriteString(" FOR ")
+		buf.WriteString(r.lock)
+		if n := len(r.lockOf); n > 0 {
+			buf.WriteString(" OF ")
+			for k, v := range r.lockOf {
+				buf.WriteString(s.FormatName(v))
+				if k < n-1 {
+					buf.WriteByte(',')
+				}
+			}
+		}
+		if r.wait != "" {
+			buf.WriteByte(' ')
+			buf.WriteString(r.wait)
+		}
+	}
+
 	return buf.String(), args, nil
 }
 
@@ -286,6 +348,12 @@ func (r *retrieve) Having(a ...Condition) *retrieve {
 	return r
 }
 
+// WINDOW `name` AS (...), see OverWindow
+func (r *retrieve) Window(name string, w *window) *retrieve {
+	r.windows = append(r.windows, namedWindow{name, w})
+	return r
+}
+
 // string or expression
 func (r *retrieve) OrderBy(a ...interface{}) *retrieve {
 	r.order = append(r.order, a...)
@@ -301,3 +369,33 @@ func (r *retrieve) Offset(n int) *retrieve {
 	r.offset = n
 	return r
 }
+
+// lock the selected rows, sqlite ignored
+func (r *retrieve) ForUpdate() *retrieve {
+	r.lock = "UPDATE"
+	return r
+}
+
+// same as ForUpdate but shared lock
+func (r *retrieve) ForShare() *retrieve {
+	r.lock = "SHARE"
+	return r
+}
+
+// skip the locked rows instead of waiting
+func (r *retrieve) SkipLocked() *retrieve {
+	r.wait = "SKIP LOCKED"
+	return r
+}
+
+// fail instead of waiting the locked rows
+func (r *retrieve) NoWait() *retrieve {
+	r.wait = "NOWAIT"
+	return r
+}
+
+// only lock the rows of the tables
+func (r *retrieve) Of(tables ...string) *retrieve {
+	r.lockOf = append(r.lockOf, tables...)
+	return r
+}
@@ -17,12 +17,14 @@ type set struct {
 }
 
 type update struct {
-	alias string
-	table string
-	set   []set
-	where []Condition
-	order []interface{}
-	limit int
+	alias   string
+	table   string
+	set     []set
+	where   []Condition
+	order   []interface{}
+	limit   int
+	returns []interface{}
+	with    *with
 }
 
 // update clause generator
@@ -37,6 +39,15 @@ func (u *update) Err() error {
 func (u *update) Expand(s Starter) (string, []interface{}, error) {
 	buf, args := new(bytes.Buffer), make([]interface{}, 0)
 
+	if u.with != nil {
+		e, a, err := u.with.expand(s, "update")
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(e)
+		args = append(args, a...)
+	}
+
 	buf.WriteString("UPDATE ")
 	buf.WriteString(s.FormatName(u.table))
 
@@ -74,6 +85,15 @@ func (u *update) Expand(s Starter) (string, []interface{}, error) {
 		args = append(args, a...)
 	}
 
+	if len(u.returns) > 0 {
+		e, a, err := returning(s, "update", u.returns)
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(e)
+		args = append(args, a...)
+	}
+
 	if n := len(u.order); n > 0 {
 		buf.WriteString(" ORDER BY ")
 		for k, v := range u.order {
@@ -128,3 +148,9 @@ func (u *update) Limit(n int) *update {
 	u.limit = n
 	return u
 }
+
+// string or expression, mysql not supported
+func (u *update) Returning(a ...interface{}) *update {
+	u.returns = append(u.returns, a...)
+	return u
+}
@@ -5,6 +5,7 @@
 package query
 
 import (
+	"errors"
 	"strings"
 )
 
@@ -72,3 +73,31 @@ func RepeatMarker(n int) string {
 	}
 	return s
 }
+
+// RETURNING ..., mysql not supported
+func returning(s Starter, clause string, a []interface{}) (string, []interface{}, error) {
+	if s.DriverName() == "mysql" {
+		return "", nil, errors.New(clause + ": returning not supported by mysql")
+	}
+
+	b, args := []string{}, make([]interface{}, 0)
+	for _, v := range a {
+		var x Expression
+		switch i := v.(type) {
+		case string:
+			x = Expr(BackQuote(i))
+		case Expression:
+			x = i
+		default:
+			return "", nil, errors.New(clause + ": returning must be string or expression")
+		}
+		e, a, err := x.Expand(s)
+		if err != nil {
+			return "", nil, err
+		}
+		b = append(b, e)
+		args = append(args, a...)
+	}
+
+	return " RETURNING " + strings.Join(b, ","), args, nil
+}
@@ -0,0 +1,177 @@
+// Copyright 2015 Chen Xianren. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package query
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+type window struct {
+	name       string
+	partition  []interface{}
+	order      []interface{}
+	frame      string
+	start, end string
+}
+
+// window specification generator
+//
+// (PARTITION BY ... ORDER BY ... ROWS BETWEEN ... AND ...)
+func Over() *window {
+	return new(window)
+}
+
+// based on the named window of the select, see retrieve.Window
+//
+// `name` or (`name` ORDER BY ...)
+func OverWindow(name string) *window {
+	return &window{name: name}
+}
+
+func (w *window) Err() error {
+	return nil
+}
+
+func (w *window) Expand(s Starter) (string, []interface{}, error) {
+	if w.name != "" && len(w.partition) == 0 && len(w.order) == 0 && w.frame == "" {
+		return s.FormatName(w.name), nil, nil
+	}
+
+	buf, args := new(bytes.Buffer), make([]interface{}, 0)
+
+	buf.WriteByte('(')
+
+	space := false
+	if w.name != "" {
+		buf.WriteString(s.FormatName(w.name))
+		space = true
+	}
+
+	for _, v := range [...]struct {
+		clause string
+		a      []interface{}
+	}{
+		{"PARTITION BY", w.partition},
+		{"ORDER BY", w.order},
+	} {
+		n := len(v.a)
+		if n == 0 {
+			continue
+		}
+		if space {
+			buf.WriteByte(' ')
+		}
+		space = true
+		buf.WriteString(v.clause)
+		buf.WriteByte(' ')
+		for k, i := range v.a {
+			var x Expression
+			switch j := i.(type) {
+			case string:
+				x = Expr(BackQuote(j))
+			case Expression:
+				x = j
+			default:
+				return "", nil, errors.New("window: " + v.clause + " must be string or expression")
+			}
+			e, a, err := x.Expand(s)
+			if err != nil {
+				return "", nil, err
+			}
+			buf.WriteString(e)
+			args = append(args, a...)
+			if k < n-1 {
+				buf.WriteByte(',')
+			}
+		}
+	}
+
+	if w.frame != "" {
+		if w.start == "" {
+			return "", nil, errors.New("window: empty frame start")
+		}
+		if space {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(w.frame)
+		if w.end != "" {
+			buf.WriteString(" BETWEEN ")
+			buf.WriteString(w.start)
+			buf.WriteString(" AND ")
+			buf.WriteString(w.end)
+		} else {
+			buf.WriteByte(' ')
+			buf.WriteString(w.start)
+		}
+	}
+
+	buf.WriteByte(')')
+
+	return buf.String(), args, nil
+}
+
+// string or expression
+func (w *window) PartitionBy(a ...interface{}) *window {
+	w.partition = append(w.partition, a...)
+	return w
+}
+
+// string or expression
+func (w *window) OrderBy(a ...interface{}) *window {
+	w.order = append(w.order, a...)
+	return w
+}
+
+// ROWS start or ROWS BETWEEN start AND end if end not empty,
+// such as UNBOUNDED PRECEDING, 1 PRECEDING, CURRENT ROW, 1 FOLLOWING, UNBOUNDED FOLLOWING
+func (w *window) Rows(start, end string) *window {
+	w.frame, w.start, w.end = "ROWS", start, end
+	return w
+}
+
+// same as Rows but RANGE
+func (w *window) Range(start, end string) *window {
+	w.frame, w.start, w.end = "RANGE", start, end
+	return w
+}
+
+func overExpr(f string, w *window) Expression {
+	if w == nil {
+		return Expr(f + " OVER ()")
+	}
+	return Expr(f+" OVER ?", w)
+}
+
+// ROW_NUMBER() OVER (...)
+func RowNumber(w *window) Expression {
+	return overExpr("ROW_NUMBER()", w)
+}
+
+// RANK() OVER (...)
+func Rank(w *window) Expression {
+	return overExpr("RANK()", w)
+}
+
+// DENSE_RANK() OVER (...)
+func DenseRank(w *window) Expression {
+	return overExpr("DENSE_RANK()", w)
+}
+
+// LAG(`k`,n) OVER (...)
+func Lag(k string, n int, w *window) Expression {
+	return overExpr("LAG("+BackQuote(k)+","+strconv.Itoa(n)+")", w)
+}
+
+// LEAD(`k`,n) OVER (...)
+func Lead(k string, n int, w *window) Expression {
+	return overExpr("LEAD("+BackQuote(k)+","+strconv.Itoa(n)+")", w)
+}
+
+// SUM(`k`) OVER (...)
+func Sum(k string, w *window) Expression {
+	return overExpr("SUM("+BackQuote(k)+")", w)
+}
@@ -0,0 +1,115 @@
+// Copyright 2015 Chen Xianren. All rights reserved.
+// Use of this source code is governed by a MIT
+// license that can be found in the LICENSE file.
+
+package query
+
+import (
+	"bytes"
+	"errors"
+)
+
+type cte struct {
+	name    string
+	columns []string
+	expr    Expression
+}
+
+type with struct {
+	recursive bool
+	ctes      []cte
+}
+
+// common table expressions generator, then Select, Update, Delete or Insert
+//
+// WITH `name` (`column`, ...) AS (expression)
+func With(name string, x Expression, columns ...string) *with {
+	return new(with).With(name, x, columns...)
+}
+
+// same as With but WITH RECURSIVE
+func WithRecursive(name string, x Expression, columns ...string) *with {
+	return new(with).WithRecursive(name, x, columns...)
+}
+
+func (w *with) With(name string, x Expression, columns ...string) *with {
+	w.ctes = append(w.ctes, cte{name, columns, x})
+	return w
+}
+
+// the whole clause is recursive
+func (w *with) WithRecursive(name string, x Expression, columns ...string) *with {
+	w.recursive = true
+	return w.With(name, x, columns...)
+}
+
+func (w *with) Select(a ...interface{}) *retrieve {
+	r := Select(a...)
+	r.with = w
+	return r
+}
+
+func (w *with) Update(table string) *update {
+	u := Update(table)
+	u.with = w
+	return u
+}
+
+func (w *with) Delete(table string) *delete {
+	d := Delete(table)
+	d.with = w
+	return d
+}
+
+// mysql not supported
+func (w *with) Insert(table string) *create {
+	c := Insert(table)
+	c.with = w
+	return c
+}
+
+// WITH ... followed by a space
+func (w *with) expand(s Starter, clause string) (string, []interface{}, error) {
+	if len(w.ctes) == 0 {
+		return "", nil, errors.New(clause + ": empty with")
+	}
+
+	buf, args := new(bytes.Buffer), make([]interface{}, 0)
+
+	buf.WriteString("WITH ")
+	if w.recursive {
+		buf.WriteString("RECURSIVE ")
+	}
+
+	for k, v := range w.ctes {
+		if v.expr == nil {
+			return "", nil, errors.New(clause + ": with nil expression")
+		}
+		buf.WriteString(s.FormatName(v.name))
+		if n := len(v.columns); n > 0 {
+			buf.WriteString(" (")
+			for i, c := range v.columns {
+				buf.WriteString(s.FormatName(c))
+				if i < n-1 {
+					buf.WriteByte(',')
+				}
+			}
+			buf.WriteByte(')')
+		}
+		buf.WriteString(" AS (")
+		e, a, err := v.expr.Expand(s)
+		if err != nil {
+			return "", nil, err
+		}
+		buf.WriteString(e)
+		args = append(args, a...)
+		buf.WriteString(")")
+		if k < len(w.ctes)-1 {
+			buf.WriteByte(',')
+		}
+	}
+
+	buf.WriteByte(' ')
+
+	return buf.String(), args, nil
+}
@@ -0,0 +1,220 @@
+package scrud
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cxr29/scrud/format"
+	"github.com/cxr29/scrud/internal/table"
+)
+
+var (
+	rmutex     = new(sync.Mutex)
+	registered []*table.Table
+)
+
+// build and validate the tables of the models and their relations at startup instead of on first use,
+// also cross-check the relations in both directions, see Verify to check against the database
+func Register(models ...interface{}) error {
+	seen := make(map[*table.Table]struct{})
+	a := make([]*table.Table, 0, len(models))
+	for _, i := range models {
+		x, err := table.NewTable(i)
+		if err != nil {
+			return err
+		}
+		if err := checkRelations(x, seen); err != nil {
+			return err
+		}
+		a = append(a, x)
+	}
+
+	rmutex.Lock()
+	defer rmutex.Unlock()
+	for _, x := range a {
+		found := false
+		for _, y := range registered {
+			if x == y {
+				found = true
+				break
+			}
+		}
+		if !found {
+			registered = append(registered, x)
+		}
+	}
+	return nil
+}
+
+// check the relation columns of the table and the related tables recursively
+func checkRelations(x *table.Table, seen map[*table.Table]struct{}) error {
+	if _, ok := seen[x]; ok {
+		return nil
+	}
+	seen[x] = struct{}{}
+
+	for _, c := range x.Columns {
+		if c.Relation == 0 {
+			continue
+		}
+		if err := checkRelation(c); err != nil {
+			return err
+		}
+		if err := checkRelations(c.RelationTable, seen); err != nil {
+			return err
+		}
+		if c.ThroughTable != nil {
+			if err := checkRelations(c.ThroughTable, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkRelation(c *table.Column) error {
+	rt := c.RelationTable
+	switch c.Relation {
+	case table.OneToMany:
+		for _, name := range c.NameLeft {
+			fk := rt.ColumnMap[name]
+			if fk == nil {
+				return &DefinitionError{Field: c.FullName(), Reason: "one_to_many column not found in relation struct " + rt.Type.Name()}
+			}
+			if fk.Relation != 0 && fk.RelationTable.Type != c.Table.Type {
+				return &DefinitionError{Field: fk.FullName(), Reason: "relation not match one_to_many " + c.FullName()}
+			}
+		}
+	case table.ManyToMany:
+		for _, rc := range rt.Columns {
+			if rc == c || rc.Relation != table.ManyToMany || rc.RelationTable.Type != c.Table.Type {
+				continue
+			}
+			if c.ThroughTable != nil {
+				if rc.ThroughTable == c.ThroughTable &&
+					(rc.ThroughLeft != c.ThroughRight || rc.ThroughRight != c.ThroughLeft) {
+					return &DefinitionError{Field: rc.FullName(), Reason: "through not match many_to_many " + c.FullName()}
+				}
+			} else if rc.ThroughTable == nil && rc.Name == c.Name &&
+				(!equalNames(rc.NameLeft, c.NameRight) || !equalNames(rc.NameRight, c.NameLeft)) {
+				return &DefinitionError{Field: rc.FullName(), Reason: "column names not match many_to_many " + c.FullName()}
+			}
+		}
+	}
+	return nil
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if a[k] != b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// table and column names to verify, no sql types so any field type is fine
+type tableNames struct {
+	name     string
+	columns  []string
+	optional bool // snapshot table, checked only if exists
+}
+
+// the struct's table, its snapshot table, then the implicit many to many and through tables
+func namesOf(x *table.Table) []tableNames {
+	columns := make([]string, 0, len(x.ColumnMap))
+	for _, c := range x.Columns {
+		if !c.IsManyRelation() {
+			columns = append(columns, c.Name)
+		}
+	}
+	tableName, idName, timeName := format.SnapshotName(x.Type.Name(), x.Name)
+	a := []tableNames{
+		{name: x.Name, columns: columns},
+		{name: tableName, columns: append([]string{idName, timeName}, columns...), optional: true},
+	}
+
+	for _, c := range x.Columns {
+		if c.Relation != table.ManyToMany {
+			continue
+		}
+		if c.ThroughTable != nil {
+			a = append(a, namesOf(c.ThroughTable)[0])
+		} else {
+			a = append(a, tableNames{name: c.Name, columns: append(append([]string{}, c.NameLeft...), c.NameRight...)})
+		}
+	}
+
+	return a
+}
+
+// check the tables and columns of the models exist in the database, include the implicit many to many tables
+// and the snapshot tables if exist, the registered models if no models
+func verify(ctx context.Context, xr faker, models ...interface{}) error {
+	var a []*table.Table
+	if len(models) == 0 {
+		rmutex.Lock()
+		a = append(a, registered...)
+		rmutex.Unlock()
+		if len(a) == 0 {
+			return newError(ErrEmptyData, "scrud: verify no models registered")
+		}
+	} else {
+		for _, i := range models {
+			x, err := table.NewTable(i)
+			if err != nil {
+				return err
+			}
+			a = append(a, x)
+		}
+	}
+
+	done := make(map[string]struct{})
+	for _, x := range a {
+		for _, d := range namesOf(x) {
+			if _, ok := done[d.name]; ok {
+				continue
+			}
+			done[d.name] = struct{}{}
+
+			m, err := tableColumns(ctx, xr, d.name)
+			if err != nil {
+				return err
+			}
+			if len(m) == 0 {
+				if d.optional {
+					continue
+				}
+				return newError(ErrSchemaMismatch, "scrud: verify table not found: "+d.name)
+			}
+			for _, c := range d.columns {
+				if _, ok := m[c]; !ok {
+					return newError(ErrSchemaMismatch, "scrud: verify column not found: "+d.name+"/"+c)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// check the tables and columns of the models or the registered models exist in the database,
+// error if no models and none registered
+func (db *DB) Verify(models ...interface{}) error {
+	return verify(context.Background(), db, models...)
+}
+
+// same as Verify with context
+func (db *DB) VerifyContext(ctx context.Context, models ...interface{}) error {
+	return verify(ctx, db, models...)
+}
+
+func (tx *Tx) Verify(models ...interface{}) error {
+	return verify(context.Background(), tx, models...)
+}
+
+func (tx *Tx) VerifyContext(ctx context.Context, models ...interface{}) error {
+	return verify(ctx, tx, models...)
+}
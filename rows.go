@@ -1,10 +1,11 @@
 package scrud
 
 import (
+	"context"
 	"database/sql"
-	"errors"
-	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/cxr29/scrud/internal/table"
 )
@@ -16,6 +17,8 @@ type Rows struct {
 	*sql.Rows
 	cnt  int
 	cols []string
+	ctx  context.Context
+	xr   faker // for hooks
 }
 
 func (r *Rows) Err() error {
@@ -23,54 +26,92 @@ func (r *Rows) Err() error {
 		return r.err
 	}
 
-	return r.Rows.Err()
+	return mapError(r.Rows.Err())
 }
 
-func (r *Rows) columns(x *table.Table) ([]*table.Column, error) {
-	m := make(map[int]struct{}, r.cnt)
-	cols := make([]*table.Column, r.cnt)
+// result column of the struct, or of the one relation field by prefix such as Customer.Name
+type scanColumn struct {
+	*table.Column
+	prefix string
+	path   []*table.Column // one relation fields from the struct
+}
+
+func (r *Rows) columns(x *table.Table) ([]scanColumn, error) {
+	m := make(map[string]struct{}, r.cnt)
+	cols := make([]scanColumn, r.cnt)
 	for k, v := range r.cols {
-		c := x.FindColumn(v)
-		if c == nil {
-			return nil, fmt.Errorf("scrud: scan column not found: %s/%s", x.Type.Name(), v)
+		var path []*table.Column
+		t, name := x, v
+		c := t.FindColumn(name)
+		for c == nil {
+			i := strings.Index(name, ".")
+			if i == -1 {
+				return nil, &ColumnNotFoundError{"scan", x.Type.Name(), v}
+			}
+			if p := t.FindField(name[:i]); p == nil || !p.IsOneRelation() {
+				return nil, &ColumnNotFoundError{"scan", x.Type.Name(), v}
+			} else {
+				path = append(path, p)
+				t, name = p.RelationTable, name[i+1:]
+			}
+			c = t.FindColumn(name)
 		}
 		if c.IsManyRelation() {
-			return nil, errors.New("scrud: scan many relation column: " + c.FullName())
+			return nil, newError(ErrInvalidData, "scrud: scan many relation column: "+c.FullName())
 		}
-		if _, ok := m[c.Index]; ok {
-			return nil, errors.New("scrud: scan column repeat: " + c.FullName())
+		prefix := v[:len(v)-len(name)]
+		if _, ok := m[prefix+c.Name]; ok {
+			return nil, newError(ErrInvalidData, "scrud: scan column repeat: "+c.FullName())
 		} else {
-			m[c.Index] = struct{}{}
+			m[prefix+c.Name] = struct{}{}
 		}
-		cols[k] = c
+		cols[k] = scanColumn{c, prefix, path}
 	}
 	return cols, nil
 }
 
-// scan one row to struct
+// scan the prefix columns to a new struct, NULL of left join keep the relation field unchanged
+type nestedScan struct {
+	path  []*table.Column
+	value reflect.Value
+	items []nestedItem
+}
+
+type nestedItem struct {
+	index  int
+	column *table.Column
+	target interface{}
+}
+
+// scan one row to struct, AfterSelect is not called as the rows are still open, use One or All instead
 func (r *Rows) Scan(i interface{}) error {
+	_, err := r.scanStruct(i)
+	return err
+}
+
+func (r *Rows) scanStruct(i interface{}) (reflect.Value, error) {
 	if r.err != nil {
-		return r.err
+		return reflect.Value{}, r.err
 	}
 
 	v := reflect.ValueOf(i)
 	t := v.Type()
 	if t.Kind() != reflect.Ptr {
-		return errors.New("scrud: scan need pointer")
+		return v, newError(ErrInvalidData, "scrud: scan need pointer")
 	}
 	t = t.Elem()
 	if t.Kind() != reflect.Struct {
-		return errors.New("scrud: scan need struct")
+		return v, newError(ErrInvalidData, "scrud: scan need struct")
 	}
 
 	x, err := table.TableOf(t)
 	if err != nil {
-		return err
+		return v, err
 	}
 
 	cols, err := r.columns(x)
 	if err != nil {
-		return err
+		return v, err
 	}
 
 	if v.IsNil() {
@@ -78,17 +119,31 @@ func (r *Rows) Scan(i interface{}) error {
 	}
 	v = v.Elem()
 
-	return r.scan(cols, v)
+	return v, r.scan(cols, v)
 }
 
-func (r *Rows) scan(cols []*table.Column, v reflect.Value) error {
+func (r *Rows) scan(cols []scanColumn, v reflect.Value) error {
 	set := make(map[int]*table.Column)
+	nested := make(map[string]*nestedScan)
+	prefixes := make([]string, 0)
 	scans := make([]interface{}, len(cols))
 	for i, c := range cols {
-		scans[i] = c.Scan(v)
-		if c.HasEncoding() || c.HasSetter() {
-			set[i] = c
+		if len(c.path) == 0 {
+			scans[i] = c.Scan(v)
+			if c.HasEncoding() || c.HasSetter() {
+				set[i] = c.Column
+			}
+			continue
+		}
+		n, ok := nested[c.prefix]
+		if !ok {
+			n = &nestedScan{path: c.path, value: reflect.New(c.Table.Type).Elem()}
+			nested[c.prefix] = n
+			prefixes = append(prefixes, c.prefix)
 		}
+		target := c.Scan(n.value)
+		n.items = append(n.items, nestedItem{i, c.Column, target})
+		scans[i] = reflect.New(reflect.TypeOf(target)).Interface() // pointer to pointer for NULL
 	}
 
 	if err := r.Rows.Scan(scans...); err != nil {
@@ -101,6 +156,41 @@ func (r *Rows) scan(cols []*table.Column, v reflect.Value) error {
 		}
 	}
 
+	sort.Strings(prefixes) // parent first
+	for _, prefix := range prefixes {
+		n := nested[prefix]
+		items := n.items[:0]
+		for _, j := range n.items {
+			if p := reflect.ValueOf(scans[j.index]).Elem(); !p.IsNil() {
+				reflect.ValueOf(j.target).Elem().Set(p.Elem())
+				items = append(items, j)
+			}
+		}
+		if len(items) == 0 {
+			continue
+		}
+
+		w := v
+		for _, c := range n.path {
+			w = c.FieldValue(w)
+			if w.Kind() == reflect.Ptr {
+				if w.IsNil() {
+					w.Set(reflect.New(c.RelationTable.Type))
+				}
+				w = w.Elem()
+			}
+		}
+		for _, j := range items {
+			if j.column.HasEncoding() || j.column.HasSetter() {
+				if err := j.column.SetValue(w, reflect.ValueOf(j.target).Elem().Interface()); err != nil {
+					return err
+				}
+			} else {
+				j.column.FieldValue(w).Set(j.column.FieldValue(n.value))
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -110,13 +200,20 @@ func (r *Rows) One(i interface{}) error {
 		return r.err
 	}
 
-	defer r.Close()
-
-	if r.Next() {
-		return r.Scan(i)
-	} else {
+	if !r.Next() {
+		r.Close()
+		if err := r.Err(); err != nil {
+			return err
+		}
 		return ErrNoRows
 	}
+
+	v, err := r.scanStruct(i)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	return hook(r.ctx, r.xr, afterSelect, v) // after close so the hook can query
 }
 
 // scan rows to slice of struct then close the rows
@@ -133,15 +230,15 @@ func (r *Rows) All(i interface{}) (err error) {
 	t := v.Type()
 
 	if t.Kind() != reflect.Ptr {
-		return errors.New("scrud: all need pointer")
+		return newError(ErrInvalidData, "scrud: all need pointer")
 	} else if v.IsNil() {
-		return errors.New("scrud: all nil")
+		return newError(ErrNilData, "scrud: all nil")
 	} else {
 		t = t.Elem()
 	}
 
 	if t.Kind() != reflect.Slice {
-		return errors.New("scrud: all need slice of struct")
+		return newError(ErrInvalidData, "scrud: all need slice of struct")
 	} else {
 		t = t.Elem()
 	}
@@ -151,7 +248,7 @@ func (r *Rows) All(i interface{}) (err error) {
 		t = t.Elem()
 	}
 	if t.Kind() != reflect.Struct {
-		return errors.New("scrud: all need slice of struct")
+		return newError(ErrInvalidData, "scrud: all need slice of struct")
 	}
 
 	x, err := table.TableOf(t)
@@ -179,8 +276,94 @@ func (r *Rows) All(i interface{}) (err error) {
 		}
 		v.Set(reflect.Append(v, j))
 	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	r.Close()
+
+	for j := 0; j < v.Len(); j++ {
+		w := v.Index(j)
+		if ptr {
+			w = w.Elem()
+		}
+		if err := hook(r.ctx, r.xr, afterSelect, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan rows to the existing elements of slice of struct in order then close the rows, such as insert returning
+//
+// rows count must equal to the slice length, nil pointer element will be allocated
+func (r *Rows) Fill(i interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	defer r.Close()
+
+	v := reflect.ValueOf(i)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return newError(ErrNilData, "scrud: fill nil")
+		}
+		v = v.Elem()
+	}
+
+	t := v.Type()
+	if k := t.Kind(); k != reflect.Slice && k != reflect.Array {
+		return newError(ErrInvalidData, "scrud: fill need slice of struct")
+	}
+	t = t.Elem()
 
-	return r.Err()
+	ptr := false
+	if t.Kind() == reflect.Ptr {
+		ptr = true
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return newError(ErrInvalidData, "scrud: fill need slice of struct")
+	}
+	if !ptr && v.Len() > 0 && !v.Index(0).CanAddr() {
+		return newError(ErrInvalidData, "scrud: fill need pointer or slice")
+	}
+
+	x, err := table.TableOf(t)
+	if err != nil {
+		return err
+	}
+
+	cols, err := r.columns(x)
+	if err != nil {
+		return err
+	}
+
+	n := 0
+	for r.Next() {
+		if n >= v.Len() {
+			return newError(ErrInvalidData, "scrud: fill rows more than length")
+		}
+		j := v.Index(n)
+		if ptr {
+			if j.IsNil() {
+				j.Set(reflect.New(t))
+			}
+			j = j.Elem()
+		}
+		if err := r.scan(cols, j); err != nil {
+			return err
+		}
+		n++
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if n != v.Len() {
+		return newError(ErrInvalidData, "scrud: fill rows less than length")
+	}
+
+	return nil
 }
 
 func (r *Rows) types(i interface{}) ([]reflect.Type, map[int]*table.Column, error) {
@@ -194,12 +377,12 @@ func (r *Rows) types(i interface{}) ([]reflect.Type, map[int]*table.Column, erro
 		}
 	case []interface{}:
 		if len(x) != r.cnt {
-			return nil, nil, errors.New("scrud: map scan slice length")
+			return nil, nil, newError(ErrInvalidData, "scrud: map scan slice length")
 		}
 
 		for k, v := range r.cols {
 			if _, ok := m[v]; ok {
-				return nil, nil, errors.New("scrud: map scan column repeat: " + v)
+				return nil, nil, newError(ErrInvalidData, "scrud: map scan column repeat: "+v)
 			} else {
 				m[v] = struct{}{}
 			}
@@ -209,7 +392,7 @@ func (r *Rows) types(i interface{}) ([]reflect.Type, map[int]*table.Column, erro
 	case map[string]interface{}:
 		for k, v := range r.cols {
 			if _, ok := m[v]; ok {
-				return nil, nil, errors.New("scrud: map scan column repeat: " + v)
+				return nil, nil, newError(ErrInvalidData, "scrud: map scan column repeat: "+v)
 			} else {
 				m[v] = struct{}{}
 			}
@@ -248,17 +431,17 @@ func (r *Rows) types(i interface{}) ([]reflect.Type, map[int]*table.Column, erro
 					}
 					for k, v := range r.cols {
 						if _, ok := m[v]; ok {
-							return nil, nil, errors.New("scrud: map scan column repeat: " + v)
+							return nil, nil, newError(ErrInvalidData, "scrud: map scan column repeat: "+v)
 						} else {
 							m[v] = struct{}{}
 						}
 
 						if c := x.FindColumn(v); c != nil {
 							if c.IsManyRelation() {
-								return nil, nil, errors.New("scrud: map scan many relation column: " + c.FullName())
+								return nil, nil, newError(ErrInvalidData, "scrud: map scan many relation column: "+c.FullName())
 							}
 							for c.IsOneRelation() {
-								c = c.RelationTable.PrimaryKey
+								c = c.RelationKey
 							}
 							if c.HasEncoding() {
 								a[k] = table.TypeByteSlice
@@ -340,6 +523,8 @@ func (r *Rows) MapOne(i interface{}) (map[string]interface{}, error) {
 
 	if r.Next() {
 		return r.MapScan(i)
+	} else if err := r.Err(); err != nil {
+		return nil, err
 	} else {
 		return nil, ErrNoRows
 	}
@@ -387,6 +572,8 @@ func (r *Rows) Row(dest ...interface{}) error {
 
 	if r.Next() {
 		return r.Rows.Scan(dest...)
+	} else if err := r.Err(); err != nil {
+		return err
 	} else {
 		return ErrNoRows
 	}
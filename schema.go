@@ -0,0 +1,674 @@
+package scrud
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/cxr29/scrud/format"
+	"github.com/cxr29/scrud/internal/table"
+	. "github.com/cxr29/scrud/query"
+)
+
+var nullTypes = map[reflect.Type]reflect.Type{
+	reflect.TypeOf(sql.NullBool{}):    reflect.TypeOf(false),
+	reflect.TypeOf(sql.NullByte{}):    reflect.TypeOf(byte(0)),
+	reflect.TypeOf(sql.NullInt16{}):   reflect.TypeOf(int16(0)),
+	reflect.TypeOf(sql.NullInt32{}):   reflect.TypeOf(int32(0)),
+	reflect.TypeOf(sql.NullInt64{}):   reflect.TypeOf(int64(0)),
+	reflect.TypeOf(sql.NullFloat64{}): reflect.TypeOf(float64(0)),
+	reflect.TypeOf(sql.NullString{}):  table.TypeString,
+	reflect.TypeOf(sql.NullTime{}):    table.TypeTime,
+}
+
+var zeroTypes = map[reflect.Type]struct{}{
+	reflect.TypeOf(Bool(false)): struct{}{},
+	reflect.TypeOf(Int(0)):      struct{}{},
+	reflect.TypeOf(Int8(0)):     struct{}{},
+	reflect.TypeOf(Int16(0)):    struct{}{},
+	reflect.TypeOf(Int32(0)):    struct{}{},
+	reflect.TypeOf(Int64(0)):    struct{}{},
+	reflect.TypeOf(Float32(0)):  struct{}{},
+	reflect.TypeOf(Float64(0)):  struct{}{},
+	reflect.TypeOf(String("")):  struct{}{},
+	reflect.TypeOf(Time{}):      struct{}{},
+}
+
+// return the column's sql type and whether it is nullable
+func columnType(driverName string, c *table.Column) (string, bool, error) {
+	for c.IsOneRelation() && c.Definition.Type == "" {
+		if c.Type.Kind() == reflect.Ptr {
+			typ, _, err := columnType(driverName, c.RelationKey)
+			return typ, true, err
+		}
+		c = c.RelationKey
+	}
+
+	if c.Definition.Type != "" {
+		return c.Definition.Type, c.Type.Kind() == reflect.Ptr, nil
+	}
+
+	if c.HasEncoding() {
+		switch driverName {
+		case "postgres":
+			return "BYTEA", false, nil
+		case "mysql":
+			if c.Encoding == "json" {
+				return "TEXT", false, nil
+			}
+		}
+		return "BLOB", false, nil
+	}
+
+	t, null := c.Type, false
+	if c.HasGetter() {
+		t = c.GetType
+	}
+	if t.Kind() == reflect.Ptr {
+		t, null = t.Elem(), true
+	}
+	if n, ok := nullTypes[t]; ok {
+		t, null = n, true
+	}
+	if _, ok := zeroTypes[t]; ok {
+		null = true
+	}
+
+	if t == table.TypeByteSlice {
+		if driverName == "postgres" {
+			return "BYTEA", null, nil
+		}
+		return "BLOB", null, nil
+	}
+	if t == table.TypeTime || (t.Kind() == reflect.Struct && t.ConvertibleTo(table.TypeTime)) {
+		switch driverName {
+		case "postgres":
+			return "TIMESTAMP WITH TIME ZONE", null, nil
+		default:
+			return "DATETIME", null, nil
+		}
+	}
+
+	var typ string
+	switch driverName {
+	case "mysql":
+		switch t.Kind() {
+		case reflect.Bool:
+			typ = "TINYINT(1)"
+		case reflect.Int8:
+			typ = "TINYINT"
+		case reflect.Uint8:
+			typ = "TINYINT UNSIGNED"
+		case reflect.Int16:
+			typ = "SMALLINT"
+		case reflect.Uint16:
+			typ = "SMALLINT UNSIGNED"
+		case reflect.Int32:
+			typ = "INT"
+		case reflect.Uint32:
+			typ = "INT UNSIGNED"
+		case reflect.Int, reflect.Int64:
+			typ = "BIGINT"
+		case reflect.Uint, reflect.Uint64:
+			typ = "BIGINT UNSIGNED"
+		case reflect.Float32:
+			typ = "FLOAT"
+		case reflect.Float64:
+			typ = "DOUBLE"
+		case reflect.String:
+			if size := c.Definition.Size; size > 0 {
+				typ = "VARCHAR(" + strconv.Itoa(size) + ")"
+			} else {
+				typ = "VARCHAR(255)"
+			}
+		}
+	case "postgres":
+		switch t.Kind() {
+		case reflect.Bool:
+			typ = "BOOLEAN"
+		case reflect.Int8, reflect.Uint8, reflect.Int16:
+			typ = "SMALLINT"
+		case reflect.Uint16, reflect.Int32:
+			typ = "INTEGER"
+		case reflect.Uint32, reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+			typ = "BIGINT"
+		case reflect.Float32:
+			typ = "REAL"
+		case reflect.Float64:
+			typ = "DOUBLE PRECISION"
+		case reflect.String:
+			if size := c.Definition.Size; size > 0 {
+				typ = "VARCHAR(" + strconv.Itoa(size) + ")"
+			} else {
+				typ = "TEXT"
+			}
+		}
+	case "sqlite":
+		switch t.Kind() {
+		case reflect.Bool:
+			typ = "BOOLEAN"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			typ = "INTEGER"
+		case reflect.Float32, reflect.Float64:
+			typ = "REAL"
+		case reflect.String:
+			typ = "TEXT"
+		}
+	default:
+		return "", false, newError(ErrUnsupportedDriver, "scrud: unsupported driver: "+driverName)
+	}
+
+	if typ == "" {
+		return "", false, &DefinitionError{Field: c.FullName(), Reason: "column type not supported"}
+	}
+
+	return typ, null, nil
+}
+
+// column options of the tag, size, type, null, default, unique, index and check
+type Definition = table.Definition
+
+// return the column options of the field or column name, data is struct or *struct
+func ColumnDefinition(data interface{}, field string) (Definition, error) {
+	x, err := table.NewTable(data)
+	if err != nil {
+		return Definition{}, err
+	}
+	c := x.FindField(field)
+	if c == nil {
+		return Definition{}, &ColumnNotFoundError{"column definition", x.Type.Name(), field}
+	}
+	return c.Definition, nil
+}
+
+// the column's sql type and whether it is nullable, include the null option
+func columnSpec(driverName string, c *table.Column) (string, bool, error) {
+	typ, null, err := columnType(driverName, c)
+	return typ, null || c.Definition.Null, err
+}
+
+// `name` type [NOT] NULL
+func columnDefinition(driverName, name string, c *table.Column) (string, error) {
+	typ, null, err := columnSpec(driverName, c)
+	if err != nil {
+		return "", err
+	}
+	s := BackQuote(name) + " " + typ
+	if null {
+		s += " NULL"
+	} else {
+		s += " NOT NULL"
+	}
+	return s, nil
+}
+
+// same as columnDefinition but to add to the existing table that may have rows,
+// NOT NULL without default option use the go zero value as default, error if the zero value not known
+func addColumnDefinition(driverName, name string, c *table.Column) (string, error) {
+	typ, null, err := columnSpec(driverName, c)
+	if err != nil {
+		return "", err
+	}
+	s := BackQuote(name) + " " + typ
+	if null {
+		return s + " NULL", nil
+	}
+	if c.Definition.Default == "" {
+		if z := zeroDefault(driverName, c); z != "" {
+			return s + " NOT NULL DEFAULT " + z, nil
+		}
+		return "", &DefinitionError{Field: c.FullName(), Reason: "added NOT NULL column need default option"}
+	}
+	return s + " NOT NULL", nil
+}
+
+// sql literal of the go zero value of the column, empty if not known such as encoding and type option
+func zeroDefault(driverName string, c *table.Column) string {
+	for c.IsOneRelation() && c.Definition.Type == "" {
+		c = c.RelationKey
+	}
+	if c.Definition.Type != "" || c.HasEncoding() {
+		return ""
+	}
+
+	t := c.Type
+	if c.HasGetter() {
+		t = c.GetType
+	}
+	if t == table.TypeTime || (t.Kind() == reflect.Struct && t.ConvertibleTo(table.TypeTime)) {
+		if driverName == "postgres" {
+			return "'0001-01-01 00:00:00+00'"
+		}
+		return "'0001-01-01 00:00:00'"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if driverName == "postgres" {
+			return "FALSE"
+		}
+		return "0"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "0"
+	case reflect.String:
+		return "''"
+	}
+	return ""
+}
+
+// DEFAULT, UNIQUE if unique and CHECK of the column, not for snapshot and many to many tables
+func columnConstraints(c *table.Column, unique bool) string {
+	var s string
+	if d := c.Definition; d.Default != "" {
+		s += " DEFAULT " + literalMarks(d.Default)
+	}
+	if unique && c.Definition.Unique && !c.PrimaryKey() {
+		s += " UNIQUE"
+	}
+	if d := c.Definition; d.Check != "" {
+		s += " CHECK (" + literalMarks(d.Check) + ")"
+	}
+	return s
+}
+
+// double the question marks of the sql expression option, as literal instead of placeholder in Expr
+func literalMarks(s string) string {
+	return strings.Replace(s, "?", "??", -1)
+}
+
+// `name` auto increment primary key column
+func autoIncrementDefinition(driverName, name string, c *table.Column) (string, bool, error) {
+	switch driverName {
+	case "mysql":
+		s, err := columnDefinition(driverName, name, c)
+		return s + " AUTO_INCREMENT", false, err
+	case "postgres":
+		switch c.Type.Kind() {
+		case reflect.Int8, reflect.Uint8, reflect.Int16, reflect.Uint16, reflect.Int32:
+			return BackQuote(name) + " SERIAL NOT NULL", false, nil
+		}
+		return BackQuote(name) + " BIGSERIAL NOT NULL", false, nil
+	case "sqlite":
+		return BackQuote(name) + " INTEGER PRIMARY KEY AUTOINCREMENT", true, nil
+	}
+	return "", false, newError(ErrUnsupportedDriver, "scrud: unsupported driver: "+driverName)
+}
+
+func joinNames(a ...string) string {
+	b := make([]string, len(a))
+	for k, v := range a {
+		b[k] = BackQuote(v)
+	}
+	return strings.Join(b, ",")
+}
+
+// table definition to create table and migrate
+type tableDefinition struct {
+	driverName string
+	name       string
+	columns    []string      // column names
+	defs       []string      // column definitions, same order as columns
+	alters     []columnAlter // to add or alter on the existing table, same order as columns
+	types      []string      // sql types to check drift, empty if auto increment, same order as columns
+	nulls      []bool        // nullable, same order as columns
+	primaryKey []string      // empty if defined inline
+	indexes    []tableIndex
+}
+
+type tableIndex struct {
+	name    string
+	columns []string
+}
+
+// the column on the existing table
+type columnAlter struct {
+	add    string // definition to add column
+	unique bool   // sqlite not allow to add UNIQUE column, create unique index after add
+	modify string // mysql definition to modify column, without UNIQUE
+	err    error  // the column can't be added
+}
+
+// append the column named name of the column definition and the constraints if constraints, not auto increment
+func (d *tableDefinition) column(name string, c *table.Column, constraints bool) error {
+	s, err := columnDefinition(d.driverName, name, c)
+	if err != nil {
+		return err
+	}
+	typ, null, err := columnSpec(d.driverName, c)
+	if err != nil {
+		return err
+	}
+	var all, rest string // the constraints with and without UNIQUE
+	if constraints {
+		all, rest = columnConstraints(c, true), columnConstraints(c, false)
+	}
+	add, err := addColumnDefinition(d.driverName, name, c)
+	a := columnAlter{add: add + all, modify: s + rest, err: err}
+	if d.driverName == "sqlite" && all != rest {
+		a.add, a.unique = add+rest, true
+	}
+	d.columns = append(d.columns, name)
+	d.defs = append(d.defs, s+all)
+	d.alters = append(d.alters, a)
+	d.types = append(d.types, typ)
+	d.nulls = append(d.nulls, null)
+	return nil
+}
+
+// statements to add the missing column k to the existing table
+func (d *tableDefinition) add(k int) ([]Expression, error) {
+	a := d.alters[k]
+	if a.err != nil {
+		return nil, a.err
+	}
+	q := []Expression{Expr("ALTER TABLE " + BackQuote(d.name) + " ADD COLUMN " + a.add)}
+	if a.unique {
+		q = append(q, Expr("CREATE UNIQUE INDEX IF NOT EXISTS "+BackQuote(d.name+"_"+d.columns[k]+"_key")+
+			" ON "+BackQuote(d.name)+" ("+BackQuote(d.columns[k])+")"))
+	}
+	return q, nil
+}
+
+// statement to alter the column k to the type and nullability of the model, nil if sqlite
+func (d *tableDefinition) alter(k int, typ bool, null bool) Expression {
+	s := "ALTER TABLE " + BackQuote(d.name)
+	switch d.driverName {
+	case "mysql":
+		return Expr(s + " MODIFY COLUMN " + d.alters[k].modify)
+	case "postgres":
+		name := BackQuote(d.columns[k])
+		var a []string
+		if typ {
+			a = append(a, " ALTER COLUMN "+name+" TYPE "+d.types[k])
+		}
+		if null && d.nulls[k] {
+			a = append(a, " ALTER COLUMN "+name+" DROP NOT NULL")
+		} else if null {
+			a = append(a, " ALTER COLUMN "+name+" SET NOT NULL")
+		}
+		return Expr(s + strings.Join(a, ","))
+	}
+	return nil
+}
+
+// create table then create index, mysql index inline
+func (d *tableDefinition) create() []Expression {
+	defs := d.defs[:len(d.defs):len(d.defs)]
+	if len(d.primaryKey) > 0 {
+		defs = append(defs, "PRIMARY KEY ("+joinNames(d.primaryKey...)+")")
+	}
+	if d.driverName == "mysql" {
+		for _, i := range d.indexes {
+			defs = append(defs, "INDEX "+BackQuote(i.name)+" ("+joinNames(i.columns...)+")")
+		}
+	}
+	a := []Expression{Expr("CREATE TABLE IF NOT EXISTS " + BackQuote(d.name) + " (" + strings.Join(defs, ", ") + ")")}
+	if d.driverName != "mysql" {
+		for _, i := range d.indexes {
+			a = append(a, d.createIndex(i))
+		}
+	}
+	return a
+}
+
+// create index on the existing table, mysql not support IF NOT EXISTS
+func (d *tableDefinition) createIndex(i tableIndex) Expression {
+	s := "CREATE INDEX "
+	if d.driverName != "mysql" {
+		s += "IF NOT EXISTS "
+	}
+	return Expr(s + BackQuote(i.name) + " ON " + BackQuote(d.name) + " (" + joinNames(i.columns...) + ")")
+}
+
+func definitionOf(driverName string, x *table.Table) (*tableDefinition, error) {
+	d := &tableDefinition{driverName: driverName, name: x.Name}
+	indexes := make(map[string]int)
+	inline := false
+	for _, c := range x.Columns {
+		if c.IsManyRelation() {
+			continue
+		}
+		if c.AutoIncrement() && (c == x.PrimaryKey || driverName != "sqlite") {
+			s, ok, err := autoIncrementDefinition(driverName, c.Name, c)
+			if err != nil {
+				return nil, err
+			}
+			inline = ok
+			s += columnConstraints(c, true)
+			a := columnAlter{add: s}
+			if inline {
+				a.err = &DefinitionError{Field: c.FullName(), Reason: "added auto_increment primary_key not supported by sqlite"}
+			}
+			d.columns = append(d.columns, c.Name)
+			d.defs = append(d.defs, s)
+			d.alters = append(d.alters, a)
+			d.types = append(d.types, "")
+			d.nulls = append(d.nulls, false)
+		} else if err := d.column(c.Name, c, true); err != nil {
+			return nil, err
+		}
+		if name := c.Definition.Index; name != "" {
+			if k, ok := indexes[name]; ok {
+				d.indexes[k].columns = append(d.indexes[k].columns, c.Name)
+			} else {
+				indexes[name] = len(d.indexes)
+				d.indexes = append(d.indexes, tableIndex{x.Name + "_" + name, []string{c.Name}}) // unique in the schema
+			}
+		}
+	}
+	if len(x.PrimaryKeys) > 0 && !inline {
+		for _, c := range x.PrimaryKeys {
+			d.primaryKey = append(d.primaryKey, c.Name)
+		}
+	}
+	return d, nil
+}
+
+// the struct's table definition, then the implicit many to many tables
+func definitionsOf(driverName string, x *table.Table) ([]*tableDefinition, error) {
+	d, err := definitionOf(driverName, x)
+	if err != nil {
+		return nil, err
+	}
+	a := []*tableDefinition{d}
+
+	for _, c := range x.Columns {
+		if c.Relation != table.ManyToMany {
+			continue
+		}
+		if c.ThroughTable != nil {
+			d, err = definitionOf(driverName, c.ThroughTable)
+		} else {
+			d = &tableDefinition{
+				driverName: driverName,
+				name:       c.Name,
+				primaryKey: append(append([]string{}, c.NameLeft...), c.NameRight...),
+			}
+			pks := append(append([]*table.Column{}, x.PrimaryKeys...), c.RelationTable.PrimaryKeys...)
+			for k, name := range d.primaryKey {
+				if err = d.column(name, pks[k], false); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, d)
+	}
+
+	return a, nil
+}
+
+// return create table query expressions of the struct, include the implicit many to many tables
+//
+// data is struct or *struct
+func CreateTable(s Starter, data interface{}) ([]Expression, error) {
+	x, err := table.NewTable(data)
+	if err != nil {
+		return nil, err
+	}
+
+	defs, err := definitionsOf(s.DriverName(), x)
+	if err != nil {
+		return nil, err
+	}
+
+	a := make([]Expression, 0, len(defs))
+	for _, d := range defs {
+		a = append(a, d.create()...)
+	}
+	return a, nil
+}
+
+// return drop table query expressions of the struct, include the implicit many to many tables
+//
+// data is struct or *struct
+func DropTable(s Starter, data interface{}) ([]Expression, error) {
+	x, err := table.NewTable(data)
+	if err != nil {
+		return nil, err
+	}
+
+	a := []Expression{Expr("DROP TABLE IF EXISTS " + BackQuote(x.Name))}
+	for _, c := range x.Columns {
+		if c.Relation != table.ManyToMany {
+			continue
+		}
+		if c.ThroughTable != nil {
+			a = append(a, Expr("DROP TABLE IF EXISTS "+BackQuote(c.ThroughTable.Name)))
+		} else {
+			a = append(a, Expr("DROP TABLE IF EXISTS "+BackQuote(c.Name)))
+		}
+	}
+
+	return a, nil
+}
+
+func runAll(ctx context.Context, xr faker, a []Expression, err error) error {
+	if err != nil {
+		return err
+	}
+	for _, q := range a {
+		if _, err := run(ctx, xr, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create table of the struct if not exists, include the implicit many to many tables
+func (db *DB) CreateTable(data interface{}) error {
+	return db.CreateTableContext(context.Background(), data)
+}
+
+// same as CreateTable with context
+func (db *DB) CreateTableContext(ctx context.Context, data interface{}) error {
+	a, err := CreateTable(db.Starter(), data)
+	return runAll(ctx, db, a, err)
+}
+
+// drop table of the struct if exists, include the implicit many to many tables
+func (db *DB) DropTable(data interface{}) error {
+	return db.DropTableContext(context.Background(), data)
+}
+
+// same as DropTable with context
+func (db *DB) DropTableContext(ctx context.Context, data interface{}) error {
+	a, err := DropTable(db.Starter(), data)
+	return runAll(ctx, db, a, err)
+}
+
+func (tx *Tx) CreateTable(data interface{}) error {
+	return tx.CreateTableContext(context.Background(), data)
+}
+
+func (tx *Tx) CreateTableContext(ctx context.Context, data interface{}) error {
+	a, err := CreateTable(tx.Starter(), data)
+	return runAll(ctx, tx, a, err)
+}
+
+func (tx *Tx) DropTable(data interface{}) error {
+	return tx.DropTableContext(context.Background(), data)
+}
+
+func (tx *Tx) DropTableContext(ctx context.Context, data interface{}) error {
+	a, err := DropTable(tx.Starter(), data)
+	return runAll(ctx, tx, a, err)
+}
+
+// return create snapshot table query expression of the struct
+//
+// data is struct or *struct
+func CreateSnapshotTable(s Starter, data interface{}) (Expression, error) {
+	x, err := table.NewTable(data)
+	if err != nil {
+		return nil, err
+	}
+
+	driverName := s.DriverName()
+	tableName, idName, timeName := format.SnapshotName(x.Type.Name(), x.Name)
+
+	id, inline, err := autoIncrementDefinition(driverName, idName, &table.Column{Table: x, Type: reflect.TypeOf(int64(0)), Getter: -1, Setter: -1})
+	if err != nil {
+		return nil, err
+	}
+	st, err := columnDefinition(driverName, timeName, &table.Column{Table: x, Type: table.TypeTime, Getter: -1, Setter: -1})
+	if err != nil {
+		return nil, err
+	}
+
+	defs := []string{id, st}
+	for _, c := range x.Columns {
+		if c.IsManyRelation() {
+			continue
+		}
+		if s, err := columnDefinition(driverName, c.Name, c); err != nil {
+			return nil, err
+		} else {
+			defs = append(defs, s)
+		}
+	}
+	if !inline {
+		defs = append(defs, "PRIMARY KEY ("+joinNames(idName)+")")
+	}
+
+	return Expr("CREATE TABLE IF NOT EXISTS " + BackQuote(tableName) + " (" + strings.Join(defs, ", ") + ")"), nil
+}
+
+// return drop snapshot table query expression of the struct
+//
+// data is struct or *struct
+func DropSnapshotTable(s Starter, data interface{}) (Expression, error) {
+	x, err := table.NewTable(data)
+	if err != nil {
+		return nil, err
+	}
+	tableName, _, _ := format.SnapshotName(x.Type.Name(), x.Name)
+	return Expr("DROP TABLE IF EXISTS " + BackQuote(tableName)), nil
+}
+
+// create snapshot table of the struct if not exists
+func (s *Snapshot) CreateTable(data interface{}) error {
+	return s.CreateTableContext(context.Background(), data)
+}
+
+func (s *Snapshot) CreateTableContext(ctx context.Context, data interface{}) error {
+	q, err := CreateSnapshotTable(s.xr.Starter(), data)
+	return runAll(ctx, s.xr, []Expression{q}, err)
+}
+
+// drop snapshot table of the struct if exists
+func (s *Snapshot) DropTable(data interface{}) error {
+	return s.DropTableContext(context.Background(), data)
+}
+
+func (s *Snapshot) DropTableContext(ctx context.Context, data interface{}) error {
+	q, err := DropSnapshotTable(s.xr.Starter(), data)
+	return runAll(ctx, s.xr, []Expression{q}, err)
+}
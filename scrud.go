@@ -4,38 +4,66 @@
 
 // Go struct/SQL CRUD
 //
-//  import "github.com/cxr29/scrud"
-//  import _ "github.com/go-sql-driver/mysql"
+//	import "github.com/cxr29/scrud"
+//	import _ "github.com/go-sql-driver/mysql"
 //
-//  db, err := scrud.Open("mysql", "user:password@/database")
+//	db, err := scrud.Open("mysql", "user:password@/database")
 //
-//  // A, B is struct or *struct
-//  n, err := db.Insert(A)                // insert
-//  n, err = db.Insert([]A{})             // batch insert
-//  err = db.Select(&A, ...)              // select by primary key, support include or exclude columns
-//  err = db.SelectRelation("B", &A, ...) // select relation field, support include or exclude columns
-//  err = db.Update(A, ...)               // update by primary key, support include or exclude columns
-//  err = db.Delete(A)                    // delete by primary key
+//	// A, B is struct or *struct
+//	n, err := db.Insert(A)                // insert
+//	n, err = db.Insert([]A{})             // batch insert
+//	n, err = db.CopyFrom([]A{})           // bulk load, postgres copy from stdin, others batch insert
+//	n, err = db.Upsert(A, ...)            // insert or update by primary key on conflict, support include or exclude columns
+//	err = db.Select(&A, ...)              // select by primary key, support include or exclude columns
+//	err = db.SelectRelation("B", &A, ...) // select relation field, support include or exclude columns
+//	err = db.Preload(&[]A{}, "B", "B.C") // load relation fields of slice of struct, one query per relation
+//	err = db.SelectWith(&A, "B", "B.C")  // select by primary key and one relation fields by left join in one query
+//	err = db.Update(A, ...)               // update by primary key, support include or exclude columns, check and increase version column
+//	err = db.Delete(A)                    // delete by primary key, soft delete if has soft_delete column
+//	err = db.HardDelete(A)                // delete by primary key permanently
+//	err = db.Unscoped().Select(&A, ...)   // include soft deleted rows
+//	err = tx.SelectForUpdate(&A, ...)     // select by primary key and lock the row until the transaction end
+//	err = db.CreateTable(A)               // create table if not exists, include many to many tables
+//	err = db.DropTable(A)                 // drop table if exists, include many to many tables
+//	plan, err := db.Migrate(false, A, B)  // create missing tables, add missing columns and alter drifted ones, dry run only return the plan
+//	err = scrud.Register(A, B)            // validate the models and their relations at startup
+//	err = db.Verify()                     // check the tables and columns of the registered models exist
 //
-//  m2m := db.ManyToMany("B", A) // many to many field manager
-//  err = m2m.Add(B, ...)        // add relation
-//  err = m2m.Set(B, ...)        // set relation, empty other
-//  err = m2m.Remove(B, ...)     // remove relation
-//  has, err := m2m.Has(B)       // check relation
-//  err = m2m.Empty()            // empty relation
+//	// more than one primary_key is a composite primary key, such as the one relation fields of a through table,
+//	// a one relation to it has one column per primary key, names separated by "|" in the tag such as `A|B,many_to_one`
 //
-//  result, err := db.Run(qe)          // run a query expression that doesn't return rows
-//  err = db.Fetch(qe).One(&A)         // run a query expression and fetch one row to struct
-//  err = db.Fetch(qe).All(&[]A{})     // run a query expression and fetch rows to slice of struct
-//  m, err := db.Fetch(qe).MapOne(nil) // run a query expression and fetch one row as map, support set column type
-//  a, err := db.Fetch(qe).MapAll(nil) // run a query expression and fetch rows as slice of map, support set column type
+//	m2m := db.ManyToMany("B", A) // many to many field manager
+//	err = m2m.Add(B, ...)        // add relation
+//	err = m2m.Set(B, ...)        // set relation, empty other
+//	err = m2m.Remove(B, ...)     // remove relation
+//	has, err := m2m.Has(B)       // check relation
+//	err = m2m.Empty()            // empty relation
+//
+//	result, err := db.Run(qe)          // run a query expression that doesn't return rows
+//	err = db.Fetch(qe).One(&A)         // run a query expression and fetch one row to struct
+//	err = db.Fetch(qe).All(&[]A{})     // run a query expression and fetch rows to slice of struct
+//	err = db.Fetch(qe).Fill([]*A{})    // run a query expression such as insert returning and fill the existing slice of struct
+//	m, err := db.Fetch(qe).MapOne(nil) // run a query expression and fetch one row as map, support set column type
+//	a, err := db.Fetch(qe).MapAll(nil) // run a query expression and fetch rows as slice of map, support set column type
+//
+//	tx, err := db.BeginTx(ctx, nil)   // begin a transaction with context and options
+//	n, err = tx.InsertContext(ctx, A) // each method above has a Context variant
+//
+//	// A implement BeforeInserter, AfterInserter, BeforeUpdater, AfterUpdater,
+//	// BeforeDeleter, AfterDeleter or AfterSelecter to hook the operations
+//	func (a *A) BeforeInsert(ctx context.Context, xr scrud.Executor) error
+//
+//	errors.Is(err, scrud.ErrUniqueViolation) // check the cause of the error, see errors.go
+//
+//	s, err := scrud.Model(A)     // read-only metadata of table and columns
+//	c := s.Field("B").Related() // metadata of the relation
 //
 // See https://github.com/cxr29/scrud for more details
 package scrud
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 	"fmt"
 	"reflect"
 	"time"
@@ -56,7 +84,7 @@ func newStarter(s string) Starter {
 	return nil
 }
 
-func insert(auto bool, xr faker, data interface{}) (int64, error) {
+func insert(ctx context.Context, auto bool, xr faker, data interface{}) (int64, error) {
 	cnt, ptr := -1, false
 
 	v := reflect.ValueOf(data)
@@ -64,7 +92,7 @@ func insert(auto bool, xr faker, data interface{}) (int64, error) {
 	k := v.Kind()
 	if k == reflect.Ptr {
 		if v.IsNil() {
-			return 0, errors.New("scrud: insert nil")
+			return 0, newError(ErrNilData, "scrud: insert nil")
 		}
 		v = v.Elem()
 		t = v.Type()
@@ -74,7 +102,7 @@ func insert(auto bool, xr faker, data interface{}) (int64, error) {
 	if k == reflect.Array || k == reflect.Slice {
 		cnt = v.Len()
 		if cnt == 0 {
-			return 0, errors.New("scrud: empty batch insert")
+			return 0, newError(ErrEmptyData, "scrud: empty batch insert")
 		}
 		t = t.Elem()
 		if t.Kind() == reflect.Ptr {
@@ -87,8 +115,9 @@ func insert(auto bool, xr faker, data interface{}) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
-
-	i := Insert(x.Name)
+	if k != reflect.Slice && !ptr && needAddr(t, v, beforeInserterType) {
+		return 0, newError(ErrInvalidData, "scrud: insert need pointer for BeforeInsert: "+x.Type.Name())
+	}
 
 	cols := make([]string, 0)
 	for _, c := range x.Columns {
@@ -98,14 +127,16 @@ func insert(auto bool, xr faker, data interface{}) (int64, error) {
 		cols = append(cols, c.Name)
 	}
 	if len(cols) == 0 {
-		return 0, errors.New("scrud: insert no columns: " + x.Type.Name())
+		return 0, newError(ErrNoColumns, "scrud: insert no columns: "+x.Type.Name())
 	}
 
-	i.Columns(cols...)
-
 	s := xr.Starter()
-	now := getTime(s.DriverName())
-	tile := func(v reflect.Value) ([]interface{}, error) {
+	driverName := s.DriverName()
+	now := getTime(driverName)
+	tile := func(xr faker, v reflect.Value) ([]interface{}, error) {
+		if err := hook(ctx, xr, beforeInsert, v); err != nil {
+			return nil, err
+		}
 		a := make([]interface{}, 0, len(x.ColumnMap))
 		for _, c := range x.Columns {
 			if c.IsManyRelation() || (auto && c.AutoIncrement()) {
@@ -126,59 +157,332 @@ func insert(auto bool, xr faker, data interface{}) (int64, error) {
 		return a, nil
 	}
 
+	autoIncrement := auto && x.AutoIncrement != nil
+
 	if cnt == -1 {
-		if a, err := tile(v); err != nil {
+		a, err := tile(xr, v)
+		if err != nil {
+			return 0, err
+		}
+		i := Insert(x.Name).Columns(cols...).Values(a...)
+
+		if autoIncrement && driverName == "postgres" {
+			var ai int64
+			err := fetch(ctx, xr, i.Returning(x.AutoIncrement.Name)).Row(&ai)
+			if err == nil {
+				err = x.AutoIncrement.SetValue(v, ai)
+			}
+			if err == nil {
+				err = hook(ctx, xr, afterInsert, v)
+			}
+			if err != nil {
+				return 0, err
+			}
+			return 1, nil
+		}
+
+		r, err := run(ctx, xr, i)
+		if err == nil && autoIncrement {
+			var ai int64
+			ai, err = r.LastInsertId()
+			if err == nil {
+				err = x.AutoIncrement.SetValue(v, ai)
+			}
+		}
+		if err == nil {
+			err = hook(ctx, xr, afterInsert, v)
+		}
+		if err != nil {
 			return 0, err
+		}
+
+		return r.RowsAffected()
+	}
+
+	if ptr {
+		for j := 0; j < cnt; j++ {
+			if v.Index(j).IsNil() {
+				return 0, newError(ErrNilData, "scrud: batch insert nil: "+x.Type.Name())
+			}
+		}
+	}
+
+	if autoIncrement && !ptr && !v.Index(0).CanAddr() {
+		autoIncrement = false // array can not set
+	}
+
+	size := xr.options().MaxPlaceholders
+	if size <= 0 {
+		size = maxPlaceholders[driverName]
+	}
+	if size /= len(cols); size < 1 {
+		size = 1
+	}
+
+	elem := func(j int) reflect.Value {
+		if w := v.Index(j); ptr {
+			return w.Elem()
 		} else {
-			i.Values(a...)
+			return w
 		}
-	} else {
+	}
+
+	// returning order is not guaranteed, match the rows to the elements by a unique column,
+	// insert one by one if no such column
+	key := returningKey(x)
+	oneByOne := autoIncrement && key == nil && (driverName == "postgres" || driverName == "sqlite")
+
+	// the before hooks run in the same transaction as the chunks
+	batch := func(xr faker) (int64, error) {
+		rows := make([][]interface{}, cnt)
 		for j := 0; j < cnt; j++ {
-			w := v.Index(j)
-			if ptr {
-				if w.IsNil() {
-					return 0, errors.New("scrud: batch insert nil: " + x.Type.Name())
+			a, err := tile(xr, elem(j))
+			if err != nil {
+				return 0, err
+			}
+			rows[j] = a
+		}
+
+		var n int64
+		for lo := 0; lo < cnt; lo += size {
+			hi := lo + size
+			if hi > cnt {
+				hi = cnt
+			}
+
+			i := Insert(x.Name).Columns(cols...)
+			for _, a := range rows[lo:hi] {
+				i.Values(a...)
+			}
+
+			if autoIncrement && (driverName == "postgres" || driverName == "sqlite") {
+				if oneByOne {
+					for j := lo; j < hi; j++ {
+						var ai int64
+						i := Insert(x.Name).Columns(cols...).Values(rows[j]...).Returning(x.AutoIncrement.Name)
+						if err := fetch(ctx, xr, i).Row(&ai); err != nil {
+							return 0, err
+						}
+						if err := x.AutoIncrement.SetValue(elem(j), ai); err != nil {
+							return 0, err
+						}
+					}
+				} else if err := returningMatch(fetch(ctx, xr, i.Returning(x.AutoIncrement.Name, key.Name)), x, key, elem, lo, hi); err != nil {
+					return 0, err
 				}
-				w = w.Elem()
+				n += int64(hi - lo)
+				continue
 			}
-			if a, err := tile(w); err != nil {
+
+			r, err := run(ctx, xr, i)
+			if err == nil && autoIncrement && xr.options().ConsecutiveAutoIncrement {
+				var ai int64
+				ai, err = r.LastInsertId() // mysql return the first id
+				for j := lo; j < hi && err == nil; j++ {
+					err = x.AutoIncrement.SetValue(elem(j), ai+int64(j-lo))
+				}
+			}
+			if err != nil {
+				return 0, err
+			}
+			if m, err := r.RowsAffected(); err != nil {
 				return 0, err
 			} else {
-				i.Values(a...)
+				n += m
+			}
+		}
+		for j := 0; j < cnt; j++ {
+			if err := hook(ctx, xr, afterInsert, elem(j)); err != nil {
+				return 0, err
 			}
 		}
+		return n, nil
 	}
 
-	autoIncrement := auto && cnt == -1 && x.AutoIncrement != nil
-	if autoIncrement && s.DriverName() == "postgres" {
-		q, a, err := i.Expand(s)
-		if err == nil {
-			var ai int64
-			err = xr.QueryRow(
-				q+" RETURNING "+s.FormatName(x.AutoIncrement.Name), a...).Scan(&ai)
-			if err == nil {
-				err = x.AutoIncrement.SetValue(v, ai)
-			}
+	if db, ok := xr.(*DB); ok && (cnt > size || (oneByOne && cnt > 1)) { // atomic as one statement
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return 0, err
 		}
+		n, err := batch(tx)
 		if err != nil {
+			tx.Rollback()
 			return 0, err
 		}
-		return 1, nil
+		return n, tx.Commit()
 	}
 
-	r, err := xr.Run(i)
-	if err == nil && autoIncrement {
+	return batch(xr)
+}
+
+// the single primary key or the first unique column of ints, uints or string, not auto increment,
+// to match the returning rows to the inserted elements
+func returningKey(x *table.Table) *table.Column {
+	for _, c := range x.Columns {
+		if c.AutoIncrement() || (c != x.PrimaryKey && !c.Definition.Unique) ||
+			c.Relation != 0 || c.HasEncoding() || c.HasGetter() || c.HasSetter() {
+			continue
+		}
+		switch c.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.String:
+			return c
+		}
+	}
+	return nil
+}
+
+// set the auto increment column of the elements lo to hi by the returning rows of auto increment and key
+func returningMatch(r *Rows, x *table.Table, key *table.Column, elem func(int) reflect.Value, lo, hi int) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.Close()
+
+	m := make(map[interface{}]int, hi-lo)
+	for j := lo; j < hi; j++ {
+		k, err := key.GetValue(elem(j))
+		if err != nil {
+			return err
+		}
+		m[preloadKey(k)] = j
+	}
+
+	n := 0
+	for r.Next() {
 		var ai int64
-		ai, err = r.LastInsertId()
-		if err == nil {
-			err = x.AutoIncrement.SetValue(v, ai)
+		var k interface{}
+		if err := r.Rows.Scan(&ai, &k); err != nil {
+			return err
+		}
+		j, ok := m[preloadKey(k)]
+		if !ok || j == -1 {
+			return newError(ErrInvalidData, "scrud: batch insert returning not match: "+key.FullName())
+		}
+		m[preloadKey(k)] = -1
+		if err := x.AutoIncrement.SetValue(elem(j), ai); err != nil {
+			return err
+		}
+		n++
+	}
+	if err := r.Err(); err != nil {
+		return err
+	}
+	if n != hi-lo {
+		return newError(ErrInvalidData, "scrud: batch insert returning less than inserted: "+key.FullName())
+	}
+	return nil
+}
+
+// default max placeholders of a batch insert chunk
+var maxPlaceholders = map[string]int{
+	"mysql":    65535,
+	"postgres": 65535,
+	"sqlite":   999,
+}
+
+func upsert(ctx context.Context, xr faker, data interface{}, columns ...string) (int64, error) {
+	v := reflect.ValueOf(data)
+	t := v.Type()
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return 0, newError(ErrNilData, "scrud: upsert nil")
+		}
+		v = v.Elem()
+		t = v.Type()
+	}
+
+	x, err := table.TableOf(t)
+	if err != nil {
+		return 0, err
+	}
+	if x.Version != nil { // no portable condition on conflict
+		return 0, newError(ErrInvalidData, "scrud: upsert version not supported: "+x.Type.Name())
+	}
+
+	if len(x.PrimaryKeys) == 0 {
+		return 0, newError(ErrNoPrimaryKey, "scrud: upsert no primary_key: "+x.Type.Name())
+	}
+	conflict := make([]string, len(x.PrimaryKeys))
+	for k, c := range x.PrimaryKeys {
+		if c.AutoIncrement() && c.FieldValue(v).IsZero() {
+			return 0, newError(ErrInvalidData, "scrud: upsert zero auto_increment: "+c.FullName())
 		}
+		conflict[k] = c.Name
 	}
+
+	columnMap, exclude, err := tidyColumns("upsert", x, columns...)
 	if err != nil {
 		return 0, err
 	}
+	count := len(columnMap)
+
+	i := Insert(x.Name).OnConflict(conflict...)
+
+	now := getTime(xr.Starter().DriverName())
+	values := make([]interface{}, 0, len(x.Columns))
+	sets := make([]string, 0, len(x.Columns))
+	for _, c := range x.Columns {
+		if c.IsManyRelation() || (c.AutoIncrement() && !c.PrimaryKey()) {
+			continue
+		}
+		if c.AutoNowAdd() {
+			values = append(values, now) // set after the statement, unchanged on conflict
+		} else if c.AutoNow() {
+			if err := c.SetValue(v, now); err != nil {
+				return 0, err
+			}
+			values = append(values, now)
+		} else if w, err := c.GetValue(v); err != nil {
+			return 0, err
+		} else {
+			values = append(values, w)
+		}
+		i.Columns(c.Name)
+
+		if c.PrimaryKey() || c.AutoNowAdd() {
+			continue
+		}
+		if count > 0 && !c.AutoNow() {
+			if _, ok := columnMap[c.Index]; (ok && exclude) || (!ok && !exclude) {
+				continue
+			}
+		}
+		sets = append(sets, c.Name)
+	}
+	i.Values(values...)
+
+	if len(sets) > 0 {
+		i.DoUpdate(sets...)
+	} else {
+		i.DoNothing()
+	}
+
+	r, err := run(ctx, xr, i)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if c := x.AutoNowAdd; c != nil {
+		pk, err := pkCond("upsert", x, nil, v)
+		if err != nil {
+			return 0, err
+		}
+		var t time.Time // now if inserted, the stored if updated or nothing
+		if err := fetch(ctx, xr, Select(c.Name).From(x.Name).Where(pk)).Row(&t); err != nil {
+			return 0, err
+		}
+		if err := c.SetValue(v, t); err != nil {
+			return 0, err
+		}
+	}
 
-	return r.RowsAffected()
+	return n, nil
 }
 
 func tidyColumns(action string, x *table.Table, columns ...string) (map[int]struct{}, bool, error) {
@@ -193,25 +497,29 @@ func tidyColumns(action string, x *table.Table, columns ...string) (map[int]stru
 	for _, i := range columns {
 		if c := x.FindField(i); c != nil {
 			if c.IsManyRelation() {
-				return nil, false, fmt.Errorf("scrud: %s %s many relation column: %s", action, include, c.FullName())
+				return nil, false, newError(ErrInvalidData, fmt.Sprintf("scrud: %s %s many relation column: %s", action, include, c.FullName()))
+			} else if _, ok := x.FieldMap[i]; ok {
+				for _, fc := range c.FieldColumns() {
+					columnMap[fc.Index] = struct{}{}
+				}
 			} else {
 				columnMap[c.Index] = struct{}{}
 			}
 		} else {
-			return nil, false, fmt.Errorf("scrud: %s %s column not found: %s/%s", action, include, x.Type.Name(), i)
+			return nil, false, &ColumnNotFoundError{action + " " + include, x.Type.Name(), i}
 		}
 	}
 	return columnMap, exclude, nil
 }
 
-func selectRelation(xr faker, field string, data interface{}, columns ...string) error {
+func selectRelation(ctx context.Context, xr faker, field string, data interface{}, columns ...string) error {
 	v := reflect.ValueOf(data)
 	t := v.Type()
 	if v.Kind() != reflect.Ptr {
-		return errors.New("scrud: select relation need pointer")
+		return newError(ErrInvalidData, "scrud: select relation need pointer")
 	}
 	if v.IsNil() {
-		return errors.New("scrud: select relation nil")
+		return newError(ErrNilData, "scrud: select relation nil")
 	}
 	v = v.Elem()
 	t = v.Type()
@@ -222,29 +530,49 @@ func selectRelation(xr faker, field string, data interface{}, columns ...string)
 	}
 
 	if c := x.FindField(field); c == nil {
-		return fmt.Errorf("scrud: select relation column not found: %s/%s", x.Type.Name(), field)
+		return &ColumnNotFoundError{"select relation", x.Type.Name(), field}
 	} else if c.IsOneRelation() {
-		v = v.Field(c.Index)
+		v = c.FieldValue(v)
 		if v.Kind() != reflect.Ptr {
 			v = v.Addr()
 		} else if v.IsNil() {
-			return errors.New("scrud: select relation nil: " + c.FullName())
+			return newError(ErrNilData, "scrud: select relation nil: "+c.FullName())
 		}
-		return retrieve(xr, v.Interface(), columns...)
+		return retrieve(ctx, xr, false, v.Interface(), columns...)
 	} else if c.IsManyRelation() {
-		pk, err := x.PrimaryKey.GetValue(v)
-		if err != nil {
-			return err
+		rt := c.RelationTable
+		var where Condition
+		if c.Relation == table.OneToMany {
+			if where, err = pkCond("select relation", x, c.NameLeft, v); err != nil {
+				return err
+			}
+		} else {
+			name, left, right := joinColumns(c)
+			if where, err = pkCond("select relation", x, left, v); err != nil {
+				return err
+			}
+			if c.ThroughTable != nil {
+				where = scope(xr, c.ThroughTable, where)
+			}
+			if len(right) == 1 {
+				where = InSelect(rt.PrimaryKeys[0].Name, Select(right[0]).From(name).Where(where))
+			} else { // correlated as IN of multiple columns is not portable
+				a := []Condition{where}
+				for k, pk := range rt.PrimaryKeys {
+					a = append(a, Cond(BackQuote(name+"."+right[k])+"="+BackQuote(rt.Name+"."+pk.Name)))
+				}
+				where = Exists(Select(right[0]).From(name).Where(a...))
+			}
 		}
 
-		columnMap, exclude, err := tidyColumns("select relation", c.RelationTable, columns...)
+		columnMap, exclude, err := tidyColumns("select relation", rt, columns...)
 		if err != nil {
 			return err
 		}
 		count := len(columnMap)
 
 		elect := make([]interface{}, 0)
-		for _, rc := range c.RelationTable.Columns {
+		for _, rc := range rt.Columns {
 			if rc.IsManyRelation() {
 				continue
 			}
@@ -256,43 +584,30 @@ func selectRelation(xr faker, field string, data interface{}, columns ...string)
 			elect = append(elect, rc.Name)
 		}
 		if len(elect) == 0 {
-			return errors.New("scrud: select relation no columns: " + c.FullName())
+			return newError(ErrNoColumns, "scrud: select relation no columns: "+c.FullName())
 		}
 
-		v = v.Field(c.Index)
+		v = c.FieldValue(v)
 		if v.Kind() != reflect.Ptr {
 			v = v.Addr()
 		} else if v.IsNil() {
 			v.Set(reflect.New(v.Type().Elem()))
 		}
 
-		if c.Relation == table.OneToMany {
-			return xr.Fetch(
-				Select(elect...).From(c.RelationTable.Name).Where(Eq(c.Name, pk))).All(v.Interface())
-		} else {
-			var q Expression
-			if c.ThroughTable != nil {
-				q = Select(c.ThroughRight.Name).From(c.ThroughTable.Name).Where(Eq(c.ThroughLeft.Name, pk))
-			} else {
-				q = Select(c.NameRight).From(c.Name).Where(Eq(c.NameLeft, pk))
-			}
-			return xr.Fetch(Select(elect...).From(c.RelationTable.Name).Where(
-				Cond("`"+c.RelationTable.PrimaryKey.Name+"` IN ?", q),
-			)).All(v.Interface())
-		}
+		return fetch(ctx, xr, Select(elect...).From(rt.Name).Where(scope(xr, rt, where))).All(v.Interface())
 	} else {
-		return errors.New("scrud: select relation column no relation: " + c.FullName())
+		return newError(ErrNotRelation, "scrud: select relation column no relation: "+c.FullName())
 	}
 }
 
-func retrieve(xr faker, data interface{}, columns ...string) error {
+func retrieve(ctx context.Context, xr faker, lock bool, data interface{}, columns ...string) error {
 	v := reflect.ValueOf(data)
 	t := v.Type()
 	if v.Kind() != reflect.Ptr {
-		return errors.New("scrud: select need pointer")
+		return newError(ErrInvalidData, "scrud: select need pointer")
 	}
 	if v.IsNil() {
-		return errors.New("scrud: select nil")
+		return newError(ErrNilData, "scrud: select nil")
 	}
 	v = v.Elem()
 	t = v.Type()
@@ -302,10 +617,7 @@ func retrieve(xr faker, data interface{}, columns ...string) error {
 		return err
 	}
 
-	if x.PrimaryKey == nil {
-		return errors.New("scrud: select no primary_key: " + x.Type.Name())
-	}
-	pk, err := x.PrimaryKey.GetValue(v)
+	pk, err := pkCond("select", x, nil, v)
 	if err != nil {
 		return err
 	}
@@ -335,10 +647,15 @@ func retrieve(xr faker, data interface{}, columns ...string) error {
 		scans = append(scans, c.Scan(v))
 	}
 	if len(elect) == 0 {
-		return errors.New("scrud: select no columns: " + x.Type.Name())
+		return newError(ErrNoColumns, "scrud: select no columns: "+x.Type.Name())
+	}
+
+	q := Select(elect...).From(x.Name).Where(scope(xr, x, pk))
+	if lock {
+		q.ForUpdate()
 	}
 
-	err = xr.Fetch(Select(elect...).From(x.Name).Where(Eq(x.PrimaryKey.Name, pk))).Row(scans...)
+	err = fetch(ctx, xr, q).Row(scans...)
 	if err != nil {
 		return err
 	}
@@ -349,15 +666,15 @@ func retrieve(xr faker, data interface{}, columns ...string) error {
 		}
 	}
 
-	return nil
+	return hook(ctx, xr, afterSelect, v)
 }
 
-func update(xr faker, data interface{}, columns ...string) error {
+func update(ctx context.Context, xr faker, data interface{}, columns ...string) error {
 	v := reflect.ValueOf(data)
 	t := v.Type()
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
-			return errors.New("scrud: update nil")
+			return newError(ErrNilData, "scrud: update nil")
 		}
 		v = v.Elem()
 		t = v.Type()
@@ -368,15 +685,20 @@ func update(xr faker, data interface{}, columns ...string) error {
 		return err
 	}
 
-	if x.PrimaryKey == nil {
-		return errors.New("scrud: update no primary key: " + x.Type.Name())
+	if needAddr(t, v, beforeUpdaterType) {
+		return newError(ErrInvalidData, "scrud: update need pointer for BeforeUpdate: "+x.Type.Name())
 	}
-	pk, err := x.PrimaryKey.GetValue(v)
+
+	pk, err := pkCond("update", x, nil, v)
 	if err != nil {
 		return err
 	}
 
-	u := Update(x.Name).Where(Eq(x.PrimaryKey.Name, pk))
+	if err := hook(ctx, xr, beforeUpdate, v); err != nil {
+		return err
+	}
+
+	u := Update(x.Name).Where(pk)
 
 	columnMap, exclude, err := tidyColumns("update", x, columns...)
 	if err != nil {
@@ -384,8 +706,15 @@ func update(xr faker, data interface{}, columns ...string) error {
 	}
 	count := len(columnMap)
 
+	var version reflect.Value
+	if c := x.Version; c != nil {
+		version = c.FieldValue(v)
+		u.Where(Eq(c.Name, version.Interface()))
+		u.Set(c.Name, Expr(BackQuote(c.Name)+"+1"))
+	}
+
 	for _, c := range x.Columns {
-		if c.IsManyRelation() || c.PrimaryKey() || c.AutoNowAdd() {
+		if c.IsManyRelation() || c.PrimaryKey() || c.AutoNowAdd() || c.Version() {
 			continue
 		}
 		if c.AutoNow() {
@@ -408,8 +737,101 @@ func update(xr faker, data interface{}, columns ...string) error {
 		}
 	}
 
-	_, err = xr.Run(u)
-	return err
+	r, err := run(ctx, xr, u)
+	if err != nil {
+		return err
+	}
+
+	if version.IsValid() {
+		if n, err := r.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrStaleObject
+		}
+		if version.CanSet() {
+			switch version.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				version.SetInt(version.Int() + 1)
+			default:
+				version.SetUint(version.Uint() + 1)
+			}
+		}
+	}
+
+	return hook(ctx, xr, afterUpdate, v)
+}
+
+// condition of the primary key or composite primary key, column name qualified if alias
+// equal condition of the primary keys of v, names are the columns refer to the primary keys,
+// the primary keys themselves if nil
+func pkCond(action string, x *table.Table, names []string, v reflect.Value) (Condition, error) {
+	values, err := pkValues(action, x, v)
+	if err != nil {
+		return nil, err
+	}
+	if names == nil {
+		names = columnNames(x.PrimaryKeys, "")
+	}
+	return keyCond(names, values), nil
+}
+
+func pkValues(action string, x *table.Table, v reflect.Value) ([]interface{}, error) {
+	if len(x.PrimaryKeys) == 0 {
+		return nil, newError(ErrNoPrimaryKey, "scrud: "+action+" no primary_key: "+x.Type.Name())
+	}
+	return columnValues(x.PrimaryKeys, v)
+}
+
+func columnValues(a []*table.Column, v reflect.Value) ([]interface{}, error) {
+	values := make([]interface{}, len(a))
+	for k, c := range a {
+		w, err := c.GetValue(v)
+		if err != nil {
+			return nil, err
+		}
+		values[k] = w
+	}
+	return values, nil
+}
+
+// qualified by the table name or alias if not empty
+func columnNames(a []*table.Column, alias string) []string {
+	names := make([]string, len(a))
+	for k, c := range a {
+		if alias != "" {
+			names[k] = alias + "." + c.Name
+		} else {
+			names[k] = c.Name
+		}
+	}
+	return names
+}
+
+func keyCond(names []string, values []interface{}) Condition {
+	a := make([]Condition, len(names))
+	for k, name := range names {
+		a[k] = Eq(name, values[k])
+	}
+	if len(a) == 1 {
+		return a[0]
+	}
+	return And(a...)
+}
+
+// the names in the keys, IN if single column otherwise OR of the key conditions
+func keysCond(names []string, keys [][]interface{}) Condition {
+	if len(names) == 1 {
+		a := make([]interface{}, len(keys))
+		for k, key := range keys {
+			a[k] = key[0]
+		}
+		return In(names[0], a...)
+	}
+	a := make([]Condition, len(keys))
+	for k, key := range keys {
+		a[k] = keyCond(names, key)
+	}
+	return Or(a...)
 }
 
 func getTime(driverName string) time.Time {
@@ -420,12 +842,13 @@ func getTime(driverName string) time.Time {
 	return t
 }
 
-func delete(xr faker, data interface{}) error {
+// delete permanently if hard or not soft_delete, otherwise set the soft_delete column to now
+func delete(ctx context.Context, xr faker, hard bool, data interface{}) error {
 	v := reflect.ValueOf(data)
 	t := v.Type()
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
-			return errors.New("scrud: delete nil")
+			return newError(ErrNilData, "scrud: delete nil")
 		}
 		v = v.Elem()
 		t = v.Type()
@@ -436,66 +859,119 @@ func delete(xr faker, data interface{}) error {
 		return err
 	}
 
-	if x.PrimaryKey == nil {
-		return errors.New("scrud: delete no primary key: " + x.Type.Name())
-	}
-	pk, err := x.PrimaryKey.GetValue(v)
+	pk, err := pkCond("delete", x, nil, v)
 	if err != nil {
 		return err
 	}
 
-	_, err = xr.Run(Delete(x.Name).Where(Eq(x.PrimaryKey.Name, pk)))
-	return err
+	if err := hook(ctx, xr, beforeDelete, v); err != nil {
+		return err
+	}
+
+	if c := x.SoftDelete; c != nil && !hard && !xr.options().unscoped {
+		now := getTime(xr.Starter().DriverName())
+		if _, err = run(ctx, xr, Update(x.Name).Set(c.Name, now).Where(pk)); err != nil {
+			return err
+		}
+		if f := c.FieldValue(v); f.CanSet() {
+			f.Set(reflect.ValueOf(&now))
+		}
+	} else if _, err = run(ctx, xr, Delete(x.Name).Where(pk)); err != nil {
+		return err
+	}
+
+	return hook(ctx, xr, afterDelete, v)
+}
+
+// add the condition to exclude soft deleted rows unless unscoped
+func scope(xr faker, x *table.Table, a ...Condition) Condition {
+	return scopeAs(xr, x, "", a...)
+}
+
+// same as scope with table name or alias qualified column
+func scopeAs(xr faker, x *table.Table, alias string, a ...Condition) Condition {
+	if c := x.SoftDelete; c != nil && !xr.options().unscoped {
+		name := c.Name
+		if alias != "" {
+			name = alias + "." + name
+		}
+		a = append(a, IsNull(name))
+	}
+	if len(a) == 1 {
+		return a[0]
+	}
+	return And(a...)
 }
 
-func fetch(xr faker, query Expression) *Rows {
+func fetch(ctx context.Context, xr faker, query Expression) *Rows {
 	var rows *sql.Rows
 	var cols []string
 	q, a, err := query.Expand(xr.Starter())
 	if err == nil {
-		rows, err = xr.Query(q, a...)
+		rows, err = xr.QueryContext(ctx, q, a...)
 		if err == nil {
 			cols, err = rows.Columns()
 		}
 	}
 	if err != nil {
-		return &Rows{err: err}
+		return &Rows{err: mapError(err)}
 	}
-	return &Rows{Rows: rows, cnt: len(cols), cols: cols}
+	return &Rows{Rows: rows, cnt: len(cols), cols: cols, ctx: ctx, xr: xr}
 }
 
-func run(xr faker, query Expression) (sql.Result, error) {
+func run(ctx context.Context, xr faker, query Expression) (sql.Result, error) {
 	q, a, err := query.Expand(xr.Starter())
 	if err != nil {
 		return nil, err
 	}
-	return xr.Exec(q, a...)
+	r, err := xr.ExecContext(ctx, q, a...)
+	return r, mapError(err)
+}
+
+// options of DB, set before use, a Tx inherit the options of its DB
+type Options struct {
+	// mysql batch insert set auto increment column by LastInsertId and consecutive ids,
+	// only safe when innodb_autoinc_lock_mode is 0 (traditional) or 1 (consecutive)
+	// and auto_increment_increment is 1, otherwise ids may be interleaved with concurrent inserts
+	ConsecutiveAutoIncrement bool
+	// max placeholders of a batch insert chunk, 0 is driver default,
+	// mysql 65535, postgres 65535 and sqlite 999,
+	// a DB run all chunks in a transaction
+	MaxPlaceholders int
+
+	unscoped bool // see Unscoped
 }
 
 type DB struct {
 	*sql.DB
 	driverName string
+	Options    Options
 }
 
 func Open(driverName, dataSourceName string) (*DB, error) {
 	switch driverName {
 	case "mysql", "postgres", "sqlite":
 	default:
-		return nil, fmt.Errorf("scrud: unsupported driver: %s", driverName)
+		return nil, newError(ErrUnsupportedDriver, "scrud: unsupported driver: "+driverName)
 	}
 	db, err := sql.Open(driverName, dataSourceName)
 	if err != nil {
 		return nil, err
 	}
-	return &DB{db, driverName}, nil
+	return &DB{DB: db, driverName: driverName}, nil
 }
 
 func (db *DB) Begin() (*Tx, error) {
-	tx, err := db.DB.Begin()
+	return db.BeginTx(context.Background(), nil)
+}
+
+// begin a transaction with context and options
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
-	return &Tx{tx, db.driverName}, nil
+	return &Tx{tx, db.driverName, db.Options}, nil
 }
 
 // return a starter to expand query expression
@@ -503,99 +979,248 @@ func (db *DB) Starter() Starter {
 	return newStarter(db.driverName)
 }
 
+func (db *DB) options() *Options {
+	return &db.Options
+}
+
 // insert struct, if have auto column data must be *struct
 //
-// batch insert if data is array or slice and not set auto increment column
+// batch insert if data is array or slice, set auto increment column by returning on postgres and sqlite
+// matched by the primary key or a unique column, otherwise insert one by one since returning order not guaranteed,
+// on mysql only if Options.ConsecutiveAutoIncrement,
+// chunk by Options.MaxPlaceholders and return the total rows affected,
+// more than one statement run in a transaction unless already in one
 func (db *DB) Insert(data interface{}) (int64, error) {
-	return insert(true, db, data)
+	return insert(context.Background(), true, db, data)
+}
+
+// same as Insert with context
+func (db *DB) InsertContext(ctx context.Context, data interface{}) (int64, error) {
+	return insert(ctx, true, db, data)
 }
 
 func (db *DB) Load(data interface{}) (int64, error) {
-	return insert(false, db, data)
+	return insert(context.Background(), false, db, data)
+}
+
+// same as Load with context
+func (db *DB) LoadContext(ctx context.Context, data interface{}) (int64, error) {
+	return insert(ctx, false, db, data)
+}
+
+// insert struct or update by primary key on conflict, if have auto now column data must be *struct
+//
+// columns specify which to update on conflict, to exclude put minus sign at the fisrt,
+// auto_now_add column only set on insert and read back the stored, auto_now column always set,
+// zero auto_increment primary key and version column not allowed, rows affected is driver specific,
+// no hooks are called since whether inserted or updated is unknown
+func (db *DB) Upsert(data interface{}, columns ...string) (int64, error) {
+	return upsert(context.Background(), db, data, columns...)
+}
+
+// same as Upsert with context
+func (db *DB) UpsertContext(ctx context.Context, data interface{}, columns ...string) (int64, error) {
+	return upsert(ctx, db, data, columns...)
 }
 
 // select by primary key, data must be *struct
 //
 // columns specify which to retrieve, to exclude put minus sign at the fisrt
 func (db *DB) Select(data interface{}, columns ...string) error {
-	return retrieve(db, data, columns...)
+	return retrieve(context.Background(), db, false, data, columns...)
+}
+
+// same as Select with context
+func (db *DB) SelectContext(ctx context.Context, data interface{}, columns ...string) error {
+	return retrieve(ctx, db, false, data, columns...)
 }
 
 // select relation field, data must be *struct
 //
 // columns specify which relation's to retrieve, to exclude put minus sign at the fisrt
 func (db *DB) SelectRelation(field string, data interface{}, columns ...string) error {
-	return selectRelation(db, field, data, columns...)
+	return selectRelation(context.Background(), db, field, data, columns...)
+}
+
+// same as SelectRelation with context
+func (db *DB) SelectRelationContext(ctx context.Context, field string, data interface{}, columns ...string) error {
+	return selectRelation(ctx, db, field, data, columns...)
 }
 
 // update by primary key, if have auto now column data must be *struct
 //
 // columns specify which to update, to exclude put minus sign at the fisrt
 func (db *DB) Update(data interface{}, columns ...string) error {
-	return update(db, data, columns...)
+	return update(context.Background(), db, data, columns...)
+}
+
+// same as Update with context
+func (db *DB) UpdateContext(ctx context.Context, data interface{}, columns ...string) error {
+	return update(ctx, db, data, columns...)
 }
 
 // delete by primary key, data must be struct or *struct
 func (db *DB) Delete(data interface{}) error {
-	return delete(db, data)
+	return delete(context.Background(), db, false, data)
+}
+
+// same as Delete with context
+func (db *DB) DeleteContext(ctx context.Context, data interface{}) error {
+	return delete(ctx, db, false, data)
+}
+
+// delete by primary key permanently, ignore soft_delete
+func (db *DB) HardDelete(data interface{}) error {
+	return delete(context.Background(), db, true, data)
+}
+
+// same as HardDelete with context
+func (db *DB) HardDeleteContext(ctx context.Context, data interface{}) error {
+	return delete(ctx, db, true, data)
+}
+
+// return a copy that include soft deleted rows and delete permanently
+func (db *DB) Unscoped() *DB {
+	d := *db
+	d.Options.unscoped = true
+	return &d
 }
 
 // fetch run a query expression that return rows, typically a select
 func (db *DB) Fetch(query Expression) *Rows {
-	return fetch(db, query)
+	return fetch(context.Background(), db, query)
+}
+
+// same as Fetch with context
+func (db *DB) FetchContext(ctx context.Context, query Expression) *Rows {
+	return fetch(ctx, db, query)
 }
 
 // run a query expression that doesn't return rows, such as insert, update and delete
 func (db *DB) Run(query Expression) (sql.Result, error) {
-	return run(db, query)
+	return run(context.Background(), db, query)
+}
+
+// same as Run with context
+func (db *DB) RunContext(ctx context.Context, query Expression) (sql.Result, error) {
+	return run(ctx, db, query)
 }
 
 type Tx struct {
 	*sql.Tx
 	driverName string
+	Options    Options
 }
 
 func (tx *Tx) Starter() Starter {
 	return newStarter(tx.driverName)
 }
 
+func (tx *Tx) options() *Options {
+	return &tx.Options
+}
+
 func (tx *Tx) Insert(data interface{}) (int64, error) {
-	return insert(true, tx, data)
+	return insert(context.Background(), true, tx, data)
+}
+
+func (tx *Tx) InsertContext(ctx context.Context, data interface{}) (int64, error) {
+	return insert(ctx, true, tx, data)
 }
 
 func (tx *Tx) Load(data interface{}) (int64, error) {
-	return insert(false, tx, data)
+	return insert(context.Background(), false, tx, data)
+}
+
+func (tx *Tx) LoadContext(ctx context.Context, data interface{}) (int64, error) {
+	return insert(ctx, false, tx, data)
+}
+
+func (tx *Tx) Upsert(data interface{}, columns ...string) (int64, error) {
+	return upsert(context.Background(), tx, data, columns...)
+}
+
+func (tx *Tx) UpsertContext(ctx context.Context, data interface{}, columns ...string) (int64, error) {
+	return upsert(ctx, tx, data, columns...)
 }
 
 func (tx *Tx) Select(data interface{}, columns ...string) error {
-	return retrieve(tx, data, columns...)
+	return retrieve(context.Background(), tx, false, data, columns...)
+}
+
+func (tx *Tx) SelectContext(ctx context.Context, data interface{}, columns ...string) error {
+	return retrieve(ctx, tx, false, data, columns...)
+}
+
+// same as Select but lock the row until the transaction end, sqlite not lock
+func (tx *Tx) SelectForUpdate(data interface{}, columns ...string) error {
+	return retrieve(context.Background(), tx, true, data, columns...)
+}
+
+// same as SelectForUpdate with context
+func (tx *Tx) SelectForUpdateContext(ctx context.Context, data interface{}, columns ...string) error {
+	return retrieve(ctx, tx, true, data, columns...)
 }
 
 func (tx *Tx) SelectRelation(field string, data interface{}, columns ...string) error {
-	return selectRelation(tx, field, data, columns...)
+	return selectRelation(context.Background(), tx, field, data, columns...)
+}
+
+func (tx *Tx) SelectRelationContext(ctx context.Context, field string, data interface{}, columns ...string) error {
+	return selectRelation(ctx, tx, field, data, columns...)
 }
 
 func (tx *Tx) Update(data interface{}, columns ...string) error {
-	return update(tx, data, columns...)
+	return update(context.Background(), tx, data, columns...)
+}
+
+func (tx *Tx) UpdateContext(ctx context.Context, data interface{}, columns ...string) error {
+	return update(ctx, tx, data, columns...)
 }
 
 func (tx *Tx) Delete(data interface{}) error {
-	return delete(tx, data)
+	return delete(context.Background(), tx, false, data)
+}
+
+func (tx *Tx) DeleteContext(ctx context.Context, data interface{}) error {
+	return delete(ctx, tx, false, data)
+}
+
+func (tx *Tx) HardDelete(data interface{}) error {
+	return delete(context.Background(), tx, true, data)
+}
+
+func (tx *Tx) HardDeleteContext(ctx context.Context, data interface{}) error {
+	return delete(ctx, tx, true, data)
+}
+
+func (tx *Tx) Unscoped() *Tx {
+	t := *tx
+	t.Options.unscoped = true
+	return &t
 }
 
 func (tx *Tx) Fetch(query Expression) *Rows {
-	return fetch(tx, query)
+	return fetch(context.Background(), tx, query)
+}
+
+func (tx *Tx) FetchContext(ctx context.Context, query Expression) *Rows {
+	return fetch(ctx, tx, query)
 }
 
 func (tx *Tx) Run(query Expression) (sql.Result, error) {
-	return run(tx, query)
+	return run(context.Background(), tx, query)
+}
+
+func (tx *Tx) RunContext(ctx context.Context, query Expression) (sql.Result, error) {
+	return run(ctx, tx, query)
 }
 
 type faker interface {
-	Exec(string, ...interface{}) (sql.Result, error)
-	Query(string, ...interface{}) (*sql.Rows, error)
-	QueryRow(string, ...interface{}) *sql.Row
+	Executor
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
 	Starter() Starter
-	Fetch(Expression) *Rows
-	Run(Expression) (sql.Result, error)
+	options() *Options
 }
@@ -6,11 +6,19 @@
 package scrud
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/cxr29/scrud/format"
+	"github.com/cxr29/scrud/internal/table"
 	. "github.com/cxr29/scrud/query"
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -43,15 +51,18 @@ func TestMySQLScrud(t *testing.T) {
 	}
 
 	defer func() {
-		_, err = db.Exec("DROP TABLE `scrud_row`")
-		if err != nil {
+		if err := db.DropTable(Row{}); err != nil {
 			t.Fatal(err)
 		}
 	}()
 
-	_, err = db.Exec("CREATE TABLE `scrud_row` (`id` INT UNSIGNED NOT NULL AUTO_INCREMENT, `c1` TINYINT NOT NULL, `c2` INT NOT NULL, `c_s` VARCHAR(255) NOT NULL, `c_t` DATETIME NOT NULL, PRIMARY KEY (`id`))")
-	if err != nil {
+	if err := db.CreateTable(Row{}); err != nil {
+		t.Fatal(err)
+	}
+	if plan, err := db.Migrate(true, Row{}); err != nil {
 		t.Fatal(err)
+	} else if len(plan) != 0 {
+		t.Fatal("migrate")
 	}
 
 	r1 := &Row{CS: "cxr"}
@@ -76,6 +87,17 @@ func TestMySQLScrud(t *testing.T) {
 		t.Fatal("select")
 	}
 
+	r1.C2, r1.CS = 3, "upsert"
+	if _, err := db.Upsert(r1, "C2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Select(r2); err != nil {
+		t.Fatal(err)
+	}
+	if r2.C2 != 3 || r2.CS != "cxr" {
+		t.Fatal("upsert")
+	}
+
 	if err := db.Delete(r2); err != nil {
 		t.Fatal(err)
 	}
@@ -90,6 +112,22 @@ func TestMySQLScrud(t *testing.T) {
 		t.Fatal("batch insert")
 	}
 
+	db.Options.ConsecutiveAutoIncrement = true
+	db.Options.MaxPlaceholders = 4 // one row a chunk
+	b := []*Row{{CS: "cxr1"}, {CS: "cxr2"}}
+	if n, err := db.Insert(b); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal("batch insert chunk")
+	}
+	if b[0].Id == 0 || b[1].Id != b[0].Id+1 {
+		t.Fatal("batch insert auto increment")
+	}
+	db.Options = Options{}
+	if _, err := db.Run(Delete("scrud_row").Where(In("id", b[0].Id, b[1].Id))); err != nil {
+		t.Fatal(err)
+	}
+
 	if err := db.Fetch(
 		Select().From("scrud_row").OrderBy("c_s"),
 	).All(&a); err != nil {
@@ -103,6 +141,68 @@ func TestMySQLScrud(t *testing.T) {
 	}
 }
 
+func TestCreateTable(t *testing.T) {
+	for _, s := range []Starter{
+		new(MySQL),
+		new(Postgres),
+		new(Sqlite),
+	} {
+		a, err := CreateTable(s, Row{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(a) != 1 {
+			t.Fatal(s.DriverName(), "create table count")
+		}
+		q, _, err := a[0].Expand(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != map[string]string{
+			"mysql":    "CREATE TABLE IF NOT EXISTS `scrud_row` (`id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT, `c1` TINYINT(1) NOT NULL, `c2` BIGINT NOT NULL, `c_s` VARCHAR(255) NOT NULL, `c_t` DATETIME NOT NULL, PRIMARY KEY (`id`))",
+			"postgres": `CREATE TABLE IF NOT EXISTS "scrud_row" ("id" BIGSERIAL NOT NULL, "c1" BOOLEAN NOT NULL, "c2" BIGINT NOT NULL, "c_s" TEXT NOT NULL, "c_t" TIMESTAMP WITH TIME ZONE NOT NULL, PRIMARY KEY ("id"))`,
+			"sqlite":   `CREATE TABLE IF NOT EXISTS "scrud_row" ("id" INTEGER PRIMARY KEY AUTOINCREMENT, "c1" BOOLEAN NOT NULL, "c2" INTEGER NOT NULL, "c_s" TEXT NOT NULL, "c_t" DATETIME NOT NULL)`,
+		}[s.DriverName()] {
+			t.Fatal(s.DriverName(), "create table", q)
+		}
+
+		a, err = CreateTable(s, Node{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(a) != 3 {
+			t.Fatal(s.DriverName(), "create table many to many count")
+		}
+		q, _, err = a[1].Expand(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != map[string]string{
+			"mysql":    "CREATE TABLE IF NOT EXISTS `ScrudNodeSibling` (`LeftId` BIGINT NOT NULL, `RightId` BIGINT NOT NULL, PRIMARY KEY (`LeftId`,`RightId`))",
+			"postgres": `CREATE TABLE IF NOT EXISTS "ScrudNodeSibling" ("LeftId" BIGINT NOT NULL, "RightId" BIGINT NOT NULL, PRIMARY KEY ("LeftId","RightId"))`,
+			"sqlite":   `CREATE TABLE IF NOT EXISTS "ScrudNodeSibling" ("LeftId" INTEGER NOT NULL, "RightId" INTEGER NOT NULL, PRIMARY KEY ("LeftId","RightId"))`,
+		}[s.DriverName()] {
+			t.Fatal(s.DriverName(), "create table many to many", q)
+		}
+
+		e, err := CreateSnapshotTable(s, Row{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, _, err = e.Expand(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q != map[string]string{
+			"mysql":    "CREATE TABLE IF NOT EXISTS `SnapshotRow` (`SnapshotId` BIGINT NOT NULL AUTO_INCREMENT, `SnapshotTime` DATETIME NOT NULL, `id` BIGINT UNSIGNED NOT NULL, `c1` TINYINT(1) NOT NULL, `c2` BIGINT NOT NULL, `c_s` VARCHAR(255) NOT NULL, `c_t` DATETIME NOT NULL, PRIMARY KEY (`SnapshotId`))",
+			"postgres": `CREATE TABLE IF NOT EXISTS "SnapshotRow" ("SnapshotId" BIGSERIAL NOT NULL, "SnapshotTime" TIMESTAMP WITH TIME ZONE NOT NULL, "id" BIGINT NOT NULL, "c1" BOOLEAN NOT NULL, "c2" BIGINT NOT NULL, "c_s" TEXT NOT NULL, "c_t" TIMESTAMP WITH TIME ZONE NOT NULL, PRIMARY KEY ("SnapshotId"))`,
+			"sqlite":   `CREATE TABLE IF NOT EXISTS "SnapshotRow" ("SnapshotId" INTEGER PRIMARY KEY AUTOINCREMENT, "SnapshotTime" DATETIME NOT NULL, "id" INTEGER NOT NULL, "c1" BOOLEAN NOT NULL, "c2" INTEGER NOT NULL, "c_s" TEXT NOT NULL, "c_t" DATETIME NOT NULL)`,
+		}[s.DriverName()] {
+			t.Fatal(s.DriverName(), "create snapshot table", q)
+		}
+	}
+}
+
 type Node struct {
 	Id              int
 	Parent          *Node   `ParentId,foreign_key`
@@ -141,22 +241,12 @@ func TestMySQLScrudNode(t *testing.T) {
 	}
 
 	defer func() {
-		_, err = db.Exec("DROP TABLE `ScrudNode`")
-		if err != nil {
-			t.Fatal(err)
-		}
-		_, err = db.Exec("DROP TABLE `ScrudNodeSibling`")
-		if err != nil {
+		if err := db.DropTable(Node{}); err != nil {
 			t.Fatal(err)
 		}
 	}()
 
-	_, err = db.Exec("CREATE TABLE `ScrudNode` (`Id` INT NOT NULL AUTO_INCREMENT, `ParentId` INT NOT NULL, `Data` VARCHAR(255) NOT NULL, `Time` DATETIME NOT NULL, PRIMARY KEY (`Id`))")
-	if err != nil {
-		t.Fatal(err)
-	}
-	_, err = db.Exec("CREATE TABLE `ScrudNodeSibling` (`LeftId` INT NOT NULL, `RightId` INT NOT NULL)")
-	if err != nil {
+	if err := db.CreateTable(Node{}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -244,6 +334,26 @@ func TestMySQLScrudNode(t *testing.T) {
 		t.Fatal("select relation many_to_many through")
 	}
 
+	node5 := &Node{Id: node3.Id}
+	if err := db.SelectWith(node5, "Parent"); err != nil {
+		t.Fatal(err)
+	} else if node5.Data != "cxr3" || node5.Parent.Data != "cxr1" || node5.Parent.Time.IsZero() {
+		t.Fatal("select with")
+	}
+
+	var nodes []Node
+	if err := db.Fetch(Select().From("ScrudNode").OrderBy("Id")).All(&nodes); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Preload(nodes, "Children", "Siblings.Parent", "ThroughSiblings"); err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 4 || len(nodes[0].Children) != 3 ||
+		len(nodes[2].Siblings) != 2 || nodes[2].Siblings[1].Parent.Data != "cxr1" ||
+		len(nodes[2].ThroughSiblings) != 2 || nodes[2].ThroughSiblings[1].Data != "cxr4" {
+		t.Fatal("preload")
+	}
+
 	err = m2m.Remove(node2, node4)
 	if err != nil {
 		t.Fatal(err)
@@ -254,3 +364,988 @@ func TestMySQLScrudNode(t *testing.T) {
 		t.Fatal("many_to_many remove")
 	}
 }
+
+type Hooked struct {
+	Id   int
+	Name string
+}
+
+func (h *Hooked) BeforeInsert(ctx context.Context, xr Executor) error {
+	if h.Name == "" {
+		return errors.New("empty name")
+	}
+	h.Name += "!"
+	return nil
+}
+
+func TestHook(t *testing.T) {
+	h := Hooked{Name: "a"}
+	if err := hook(context.Background(), nil, beforeInsert, reflect.ValueOf(&h).Elem()); err != nil {
+		t.Fatal(err)
+	} else if h.Name != "a!" {
+		t.Fatal(h.Name)
+	}
+	if err := hook(context.Background(), nil, beforeInsert, reflect.ValueOf(h)); err != nil {
+		t.Fatal(err)
+	} else if h.Name != "a!" { // not addressable, called on a copy
+		t.Fatal(h.Name)
+	}
+	if err := hook(context.Background(), nil, beforeInsert, reflect.ValueOf(Hooked{})); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := hook(context.Background(), nil, afterInsert, reflect.ValueOf(&h).Elem()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type SoftRow struct {
+	Id        int
+	DeletedAt *time.Time `,soft_delete`
+}
+
+func TestScope(t *testing.T) {
+	x, err := table.TableOf(reflect.TypeOf(SoftRow{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &DB{driverName: "mysql"}
+	if q, a, err := Select().From(x.Name).Where(scope(db, x, Eq("Id", 1))).Expand(new(MySQL)); err != nil {
+		t.Fatal(err)
+	} else if q != "SELECT * FROM `SoftRow` WHERE (`Id`=?) AND (`DeletedAt` IS NULL)" || len(a) != 1 {
+		t.Fatal(q, a)
+	}
+	if q, _, err := Select().From(x.Name).Where(scope(db.Unscoped(), x, Eq("Id", 1))).Expand(new(MySQL)); err != nil {
+		t.Fatal(err)
+	} else if q != "SELECT * FROM `SoftRow` WHERE `Id`=?" {
+		t.Fatal(q)
+	}
+}
+
+type mysqlError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *mysqlError) Error() string { return e.Message }
+
+type pqError struct {
+	Code    string
+	Message string
+}
+
+func (e pqError) Error() string { return e.Message }
+
+type sqliteError struct {
+	code int
+	msg  string
+}
+
+func (e *sqliteError) Error() string { return e.msg }
+func (e *sqliteError) Code() int     { return e.code }
+
+type sqlite3Error struct {
+	Code         int
+	ExtendedCode int
+	msg          string
+}
+
+func (e sqlite3Error) Error() string { return e.msg }
+
+func TestMapError(t *testing.T) {
+	for _, i := range []struct {
+		err  error
+		kind error
+	}{
+		{&mysqlError{1062, "duplicate"}, ErrUniqueViolation},
+		{&mysqlError{1452, "foreign key"}, ErrForeignKeyViolation},
+		{&mysqlError{1045, "access denied"}, nil},
+		{pqError{"23505", "duplicate"}, ErrUniqueViolation},
+		{pqError{"23503", "foreign key"}, ErrForeignKeyViolation},
+		{&sqliteError{2067, "unique"}, ErrUniqueViolation},
+		{&sqliteError{1555, "primary key"}, ErrUniqueViolation},
+		{&sqliteError{787, "foreign key"}, ErrForeignKeyViolation},
+		{&sqliteError{19, "constraint"}, nil},
+		{sqlite3Error{19, 2067, "unique"}, ErrUniqueViolation},
+		{errors.New("other"), nil},
+	} {
+		err := mapError(i.err)
+		if err.Error() != i.err.Error() || !errors.Is(err, i.err) {
+			t.Fatal(err)
+		}
+		if i.kind != nil && !errors.Is(err, i.kind) {
+			t.Fatal(err, i.kind)
+		}
+		if errors.Is(err, ErrUniqueViolation) && i.kind != ErrUniqueViolation {
+			t.Fatal(err)
+		}
+	}
+
+	var e *DefinitionError
+	if _, err := table.TableOf(reflect.TypeOf(0)); !errors.As(err, &e) {
+		t.Fatal(err)
+	}
+}
+
+func TestScanColumns(t *testing.T) {
+	x, err := table.TableOf(reflect.TypeOf(Node{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Rows{cols: []string{"Id", "Parent.Data", "Parent.Parent.Id"}, cnt: 3}
+	if cols, err := r.columns(x); err != nil {
+		t.Fatal(err)
+	} else if len(cols[0].path) != 0 ||
+		len(cols[1].path) != 1 || cols[1].prefix != "Parent." || cols[1].Field != "Data" ||
+		len(cols[2].path) != 2 || cols[2].prefix != "Parent.Parent." || cols[2].Field != "Id" {
+		t.Fatal(cols)
+	}
+	r = &Rows{cols: []string{"Data.Id"}, cnt: 1}
+	if _, err := r.columns(x); err == nil {
+		t.Fatal("not one relation")
+	}
+}
+
+type Tenanted struct {
+	TenantId int `,primary_key`
+	Id       int `,primary_key`
+	Data     string
+}
+
+func TestPkCond(t *testing.T) {
+	x, err := table.TableOf(reflect.TypeOf(Tenanted{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := pkCond("select", x, nil, reflect.ValueOf(Tenanted{1, 2, ""}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q, a, err := Delete(x.Name).Where(pk).Expand(new(MySQL)); err != nil {
+		t.Fatal(err)
+	} else if q != "DELETE FROM `Tenanted` WHERE (`TenantId`=?) AND (`Id`=?)" || len(a) != 2 || a[0] != 1 || a[1] != 2 {
+		t.Fatal(q, a)
+	}
+	if a, err := CreateTable(new(Postgres), Tenanted{}); err != nil {
+		t.Fatal(err)
+	} else if q, _, _ := a[0].Expand(new(Postgres)); !strings.HasSuffix(q, `PRIMARY KEY ("TenantId","Id"))`) {
+		t.Fatal(q)
+	}
+}
+
+type Product struct {
+	Id    int
+	Name  string   `,size=64,unique,index=name_stock`
+	Stock int      "scrud:\",default=0,check=`Stock`>=0,index=name_stock\""
+	Price *float64 `,type=DECIMAL(10,2),null`
+}
+
+// question marks of the options are literal
+type Noted struct {
+	Id   int
+	Note string "scrud:\",size=8,default='?',check=`Note`<>'a?b'\""
+}
+
+func TestColumnDefinition(t *testing.T) {
+	if d, err := ColumnDefinition(Product{}, "Name"); err != nil {
+		t.Fatal(err)
+	} else if d.Size != 64 || !d.Unique {
+		t.Fatal(d)
+	}
+
+	for k, v := range map[Starter][]string{
+		new(MySQL): {
+			"CREATE TABLE IF NOT EXISTS `Product` (`Id` BIGINT NOT NULL AUTO_INCREMENT, `Name` VARCHAR(64) NOT NULL UNIQUE, `Stock` BIGINT NOT NULL DEFAULT 0 CHECK (`Stock`>=0), `Price` DECIMAL(10,2) NULL, PRIMARY KEY (`Id`), INDEX `Product_name_stock` (`Name`,`Stock`))",
+		},
+		new(Postgres): {
+			`CREATE TABLE IF NOT EXISTS "Product" ("Id" BIGSERIAL NOT NULL, "Name" VARCHAR(64) NOT NULL UNIQUE, "Stock" BIGINT NOT NULL DEFAULT 0 CHECK ("Stock">=0), "Price" DECIMAL(10,2) NULL, PRIMARY KEY ("Id"))`,
+			`CREATE INDEX IF NOT EXISTS "Product_name_stock" ON "Product" ("Name","Stock")`,
+		},
+	} {
+		a, err := CreateTable(k, Product{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(a) != len(v) {
+			t.Fatal(len(a))
+		}
+		for i, q := range a {
+			if s, _, err := q.Expand(k); err != nil {
+				t.Fatal(err)
+			} else if s != v[i] {
+				t.Fatal(s)
+			}
+		}
+	}
+
+	a, err := CreateTable(new(MySQL), Noted{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, args, err := a[0].Expand(new(MySQL)); err != nil {
+		t.Fatal(err)
+	} else if s != "CREATE TABLE IF NOT EXISTS `Noted` (`Id` BIGINT NOT NULL AUTO_INCREMENT, `Note` VARCHAR(8) NOT NULL DEFAULT '?' CHECK (`Note`<>'a?b'), PRIMARY KEY (`Id`))" || len(args) != 0 {
+		t.Fatal(s, args)
+	}
+}
+
+func TestModel(t *testing.T) {
+	s, err := Model([]*Node{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "ScrudNode" || len(s.PrimaryKeys) != 1 || s.PrimaryKeys[0] != "Id" {
+		t.Fatal(s.Name, s.PrimaryKeys)
+	}
+
+	if c := s.Field("Id"); c == nil || !c.PrimaryKey || !c.AutoIncrement {
+		t.Fatal(c)
+	}
+	if c := s.Column("Time"); c == nil || !c.AutoNowAdd || c.Type != reflect.TypeOf(time.Time{}) {
+		t.Fatal(c)
+	}
+
+	c := s.Column("ParentId")
+	if c == nil || c.Field != "Parent" || c.Relation != "many_to_one" || c.Related().Name != "ScrudNode" {
+		t.Fatal(c)
+	}
+	if c := s.Field("Siblings"); c.Relation != "many_to_many" || c.JoinTable != "ScrudNodeSibling" ||
+		strings.Join(c.JoinLeft, "|") != "LeftId" || strings.Join(c.JoinRight, "|") != "RightId" || c.Through() != nil {
+		t.Fatal(c)
+	}
+	if c := s.Field("ThroughSiblings"); c.JoinTable != "ScrudNodeSibling" || c.Through().Type != reflect.TypeOf(ScrudNodeSibling{}) {
+		t.Fatal(c)
+	}
+	if c := s.Field("Data"); c.Relation != "" || c.Related() != nil {
+		t.Fatal(c)
+	}
+}
+
+type RegOrder struct {
+	Id    int
+	Items []*RegItem `,one_to_many`
+}
+
+type RegItem struct {
+	Id   int
+	Name string
+}
+
+type RegLeft struct {
+	Id     int
+	Rights []*RegRight `RegLeftRight|LeftId|RightId,many_to_many`
+}
+
+type RegRight struct {
+	Id    int
+	Lefts []*RegLeft `RegLeftRight|LeftId|RightId,many_to_many`
+}
+
+func TestRegister(t *testing.T) {
+	defer func(a []*table.Table) { registered = a }(registered)
+	registered = nil
+
+	db, f := newFakeDB("sqlite", nil)
+	if err := db.Verify(); !errors.Is(err, ErrEmptyData) || len(f.queries) != 0 {
+		t.Fatal(err)
+	}
+
+	if err := Register(Node{}, new(ScrudNodeSibling)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Verify(); !errors.Is(err, ErrSchemaMismatch) || len(f.queries) != 1 {
+		t.Fatal(err)
+	}
+
+	var e *DefinitionError
+	for _, i := range []interface{}{RegOrder{}, RegLeft{}} {
+		if err := Register(i); !errors.As(err, &e) {
+			t.Fatal(err)
+		}
+	}
+}
+
+// no sql type mapping, verify needs only the names
+type Point struct{ X, Y float64 }
+
+func (p Point) Value() (driver.Value, error) { return nil, nil }
+
+type Located struct {
+	Id    int
+	Point Point
+}
+
+func TestVerifyNames(t *testing.T) {
+	db, f := newFakeDB("sqlite", func(q string, args []driver.Value) *fakeResult {
+		if len(args) == 1 && args[0] == "Located" {
+			return &fakeResult{cols: []string{"name", "type", "notnull"}, rows: [][]driver.Value{
+				{"Id", "INTEGER", int64(1)}, {"Point", "TEXT", int64(0)},
+			}}
+		}
+		return &fakeResult{cols: []string{"name", "type", "notnull"}} // no snapshot table
+	})
+	if _, err := CreateTable(db.Starter(), Located{}); err == nil {
+		t.Fatal("mapped")
+	}
+	if err := db.Verify(Located{}); err != nil || len(f.queries) != 2 {
+		t.Fatal(err, f.queries)
+	}
+}
+
+// fake driver record the queries and answer them by the handler, to test without a database
+type fakeDB struct {
+	queries []string
+	args    [][]driver.Value
+	handle  func(string, []driver.Value) *fakeResult
+}
+
+// rows of query, or last insert id and rows affected of exec
+type fakeResult struct {
+	cols []string
+	rows [][]driver.Value
+	id   int64
+	n    int64
+	err  error
+}
+
+func (r *fakeResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r *fakeResult) RowsAffected() (int64, error) { return r.n, nil }
+
+func newFakeDB(driverName string, handle func(string, []driver.Value) *fakeResult) (*DB, *fakeDB) {
+	f := &fakeDB{handle: handle}
+	return &DB{DB: sql.OpenDB(f), driverName: driverName}, f
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return f }
+func (f *fakeDB) Open(string) (driver.Conn, error)             { return &fakeConn{f}, nil }
+
+func (f *fakeDB) result(q string, args []driver.Value) *fakeResult {
+	f.queries = append(f.queries, q)
+	f.args = append(f.args, args)
+	if f.handle != nil {
+		if r := f.handle(q, args); r != nil {
+			return r
+		}
+	}
+	return &fakeResult{n: 1}
+}
+
+type fakeConn struct {
+	f *fakeDB
+}
+
+func (c *fakeConn) Prepare(q string) (driver.Stmt, error) { return &fakeStmt{c.f, q}, nil }
+func (c *fakeConn) Close() error                          { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)             { c.f.result("BEGIN", nil); return c, nil }
+func (c *fakeConn) Commit() error                         { c.f.result("COMMIT", nil); return nil }
+func (c *fakeConn) Rollback() error                       { c.f.result("ROLLBACK", nil); return nil }
+
+type fakeStmt struct {
+	f *fakeDB
+	q string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	r := s.f.result(s.q, args)
+	return r, r.err
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	r := s.f.result(s.q, args)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &fakeRows{r, 0}, nil
+}
+
+type fakeRows struct {
+	r *fakeResult
+	i int
+}
+
+func (r *fakeRows) Columns() []string { return r.r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.r.rows[r.i])
+	r.i++
+	return nil
+}
+
+type Migrated struct {
+	Id    int
+	Name  string
+	Score float64 `,index=score`
+	Note  *string
+	At    time.Time `,index=at`
+}
+
+func TestMigrate(t *testing.T) {
+	db, f := newFakeDB("mysql", func(q string, args []driver.Value) *fakeResult {
+		if strings.Contains(q, "information_schema`.`statistics") {
+			return &fakeResult{cols: []string{"index_name"}, rows: [][]driver.Value{{"PRIMARY"}, {"Migrated_at"}}}
+		}
+		if strings.Contains(q, "information_schema") && args[0] == "Migrated" {
+			return &fakeResult{
+				cols: []string{"column_name", "column_type", "is_nullable"},
+				rows: [][]driver.Value{
+					{"Id", "bigint(20)", "NO"},
+					{"Name", "varchar(64)", "YES"},
+				},
+			}
+		}
+		return &fakeResult{cols: []string{"column_name", "column_type", "is_nullable"}}
+	})
+
+	plan, err := db.Migrate(true, Migrated{}, Row{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"ALTER TABLE `Migrated` MODIFY COLUMN `Name` VARCHAR(255) NOT NULL",
+		"ALTER TABLE `Migrated` ADD COLUMN `Score` DOUBLE NOT NULL DEFAULT 0",
+		"ALTER TABLE `Migrated` ADD COLUMN `Note` VARCHAR(255) NULL",
+		"ALTER TABLE `Migrated` ADD COLUMN `At` DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00'",
+		"CREATE INDEX `Migrated_score` ON `Migrated` (`Score`)",
+		"CREATE TABLE IF NOT EXISTS `scrud_row` (",
+	}
+	if len(plan) != len(want) {
+		t.Fatal(len(plan))
+	}
+	for k, v := range plan {
+		if q, _, err := v.Expand(new(MySQL)); err != nil {
+			t.Fatal(err)
+		} else if !strings.HasPrefix(q, want[k]) || (k < 5 && q != want[k]) {
+			t.Fatal(q)
+		}
+	}
+	for _, q := range f.queries {
+		if !strings.HasPrefix(q, "SELECT") {
+			t.Fatal("dry run", q)
+		}
+	}
+
+	for _, i := range []struct {
+		driverName, want, have string
+		same                   bool
+	}{
+		{"mysql", "INT", "int(11)", true},
+		{"mysql", "BIGINT UNSIGNED", "bigint(20) unsigned", true},
+		{"mysql", "TINYINT(1)", "tinyint(4)", false},
+		{"postgres", "VARCHAR(64)", "character varying(64)", true},
+		{"postgres", "DECIMAL(10, 2)", "numeric(10,2)", true},
+		{"postgres", "TEXT", "character varying(64)", false},
+		{"sqlite", "INTEGER", "integer", true},
+	} {
+		if sameType(i.driverName, i.want, i.have) != i.same {
+			t.Fatal(i)
+		}
+	}
+}
+
+type MigratedCode struct {
+	Id   int
+	Code string `,unique`
+	Name string
+}
+
+type Unmigratable struct {
+	Id     int
+	Amount float64 `,type=DECIMAL(10,2)`
+}
+
+func TestMigrateDrift(t *testing.T) {
+	for driverName, want := range map[string][]string{
+		"postgres": {
+			`ALTER TABLE "MigratedCode" ADD COLUMN "Code" TEXT NOT NULL DEFAULT '' UNIQUE`,
+			`ALTER TABLE "MigratedCode" ALTER COLUMN "Name" TYPE TEXT, ALTER COLUMN "Name" SET NOT NULL`,
+		},
+		"sqlite": {
+			`ALTER TABLE "MigratedCode" ADD COLUMN "Code" TEXT NOT NULL DEFAULT ''`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS "MigratedCode_Code_key" ON "MigratedCode" ("Code")`,
+		},
+	} {
+		db, _ := newFakeDB(driverName, func(q string, args []driver.Value) *fakeResult {
+			if driverName == "sqlite" {
+				return &fakeResult{cols: []string{"name", "type", "notnull"},
+					rows: [][]driver.Value{{"Id", "INTEGER", int64(1)}, {"Name", "integer", int64(0)}}}
+			}
+			return &fakeResult{cols: []string{"column_name", "data_type", "character_maximum_length", "numeric_precision", "numeric_scale", "is_nullable"},
+				rows: [][]driver.Value{{"Id", "bigint", nil, int64(64), int64(0), "NO"}, {"Name", "integer", nil, int64(32), int64(0), "YES"}}}
+		})
+
+		plan, err := db.Migrate(true, MigratedCode{})
+		var e *DriftError
+		if driverName == "sqlite" {
+			if !errors.As(err, &e) || !errors.Is(err, ErrSchemaMismatch) || len(e.Columns) != 1 ||
+				e.Columns[0].Column != "Name" || e.Columns[0].Want != "TEXT NOT NULL" || e.Columns[0].Have != "integer NULL" {
+				t.Fatal(err)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if len(plan) != len(want) {
+			t.Fatal(driverName, len(plan))
+		}
+		for k, v := range plan {
+			if q, _, err := v.Expand(db.Starter()); err != nil || q != want[k] {
+				t.Fatal(driverName, q, err)
+			}
+		}
+
+		var d *DefinitionError
+		if _, err := db.Migrate(true, Unmigratable{}); !errors.As(err, &d) || d.Field != "Unmigratable.Amount" {
+			t.Fatal(err)
+		}
+	}
+}
+
+type Upserted struct {
+	Id      int
+	Name    string
+	Created time.Time `,auto_now_add`
+	Updated time.Time `,auto_now`
+}
+
+func TestUpsert(t *testing.T) {
+	created := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	db, f := newFakeDB("postgres", func(q string, args []driver.Value) *fakeResult {
+		if strings.HasPrefix(q, "SELECT") {
+			return &fakeResult{cols: []string{"Created"}, rows: [][]driver.Value{{created}}}
+		}
+		return nil
+	})
+
+	u := &Upserted{Id: 1, Name: "cxr"}
+	if _, err := db.Upsert(u); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.queries) != 2 ||
+		f.queries[0] != `INSERT INTO "Upserted" ("Id","Name","Created","Updated") VALUES ($1,$2,$3,$4) ON CONFLICT ("Id") DO UPDATE SET "Name"=EXCLUDED."Name","Updated"=EXCLUDED."Updated"` ||
+		f.queries[1] != `SELECT "Created" FROM "Upserted" WHERE "Id"=$1` {
+		t.Fatal(f.queries)
+	}
+	if !u.Created.Equal(created) || u.Updated.IsZero() {
+		t.Fatal(u)
+	}
+}
+
+type Imported struct {
+	Id   int
+	Code string `,unique`
+}
+
+func TestInsertReturning(t *testing.T) {
+	id := int64(20)
+	db, f := newFakeDB("sqlite", func(q string, args []driver.Value) *fakeResult {
+		if strings.HasSuffix(q, `RETURNING "Id","Code"`) { // out of order
+			return &fakeResult{cols: []string{"Id", "Code"}, rows: [][]driver.Value{{int64(12), "c"}, {int64(10), "a"}, {int64(11), "b"}}}
+		} else if strings.Contains(q, "RETURNING") {
+			if id++; id == 0 {
+				return &fakeResult{err: errors.New("second row")}
+			}
+			return &fakeResult{cols: []string{"id"}, rows: [][]driver.Value{{id}}}
+		}
+		return nil
+	})
+
+	a := []*Imported{{Code: "a"}, {Code: "b"}, {Code: "c"}}
+	if n, err := db.Insert(a); err != nil {
+		t.Fatal(err)
+	} else if n != 3 || a[0].Id != 10 || a[1].Id != 11 || a[2].Id != 12 {
+		t.Fatal(n, a[0], a[1], a[2])
+	}
+	if len(f.queries) != 1 {
+		t.Fatal(f.queries)
+	}
+
+	f.queries = nil
+	b := []*Row{{CS: "a"}, {CS: "b"}}
+	if n, err := db.Insert(b); err != nil {
+		t.Fatal(err)
+	} else if n != 2 || len(f.queries) != 4 || f.queries[0] != "BEGIN" || f.queries[3] != "COMMIT" || b[0].Id != 21 || b[1].Id != 22 {
+		t.Fatal(n, f.queries)
+	}
+
+	f.queries = nil
+	id = -2 // the handler fail the second row
+	if _, err := db.Insert([]*Row{{CS: "a"}, {CS: "b"}}); err == nil || f.queries[len(f.queries)-1] != "ROLLBACK" {
+		t.Fatal(err, f.queries)
+	}
+
+	f.queries = nil
+	c := []*Imported{{Code: "a"}, {Code: "x"}, {Code: "c"}}
+	if _, err := db.Insert(c); err == nil {
+		t.Fatal("returning not match")
+	}
+}
+
+type TxHooked struct {
+	Id   int
+	InTx bool
+}
+
+func (h *TxHooked) BeforeInsert(ctx context.Context, xr Executor) error {
+	_, h.InTx = xr.(*Tx)
+	return nil
+}
+
+func TestInsertChunk(t *testing.T) {
+	inserts := 0
+	db, f := newFakeDB("mysql", func(q string, args []driver.Value) *fakeResult {
+		if strings.HasPrefix(q, "INSERT") {
+			if inserts++; inserts == 2 {
+				return &fakeResult{err: errors.New("second chunk")}
+			}
+		}
+		return nil
+	})
+	db.Options.MaxPlaceholders = 1 // one row a chunk
+
+	a := []*TxHooked{{}, {}}
+	if _, err := db.Insert(a); err == nil || err.Error() != "second chunk" {
+		t.Fatal(err)
+	}
+	if !a[0].InTx || !a[1].InTx {
+		t.Fatal("before insert not in transaction")
+	}
+	if len(f.queries) != 4 || f.queries[0] != "BEGIN" || f.queries[3] != "ROLLBACK" {
+		t.Fatal(f.queries)
+	}
+}
+
+type Copied struct {
+	Id   int
+	Name string
+}
+
+func TestCopyFrom(t *testing.T) {
+	db, f := newFakeDB("postgres", nil)
+	a := []Copied{{1, "a"}, {2, "b"}}
+	if n, err := db.CopyFrom(a); err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+	copyIn := `COPY "Copied" ("Id","Name") FROM STDIN`
+	if len(f.queries) != 5 || f.queries[0] != "BEGIN" || f.queries[4] != "COMMIT" {
+		t.Fatal(f.queries)
+	}
+	for i := 1; i < 4; i++ {
+		if f.queries[i] != copyIn {
+			t.Fatal(f.queries[i])
+		}
+	}
+	if len(f.args[1]) != 2 || f.args[1][0] != int64(1) || f.args[1][1] != "a" || len(f.args[3]) != 0 {
+		t.Fatal(f.args)
+	}
+
+	db, f = newFakeDB("mysql", func(q string, args []driver.Value) *fakeResult {
+		return &fakeResult{id: 1, n: 2}
+	})
+	if n, err := db.CopyFrom(a); err != nil || n != 2 {
+		t.Fatal(n, err)
+	}
+	if len(f.queries) != 1 || f.queries[0] != "INSERT INTO `Copied` (`Id`,`Name`) VALUES (?,?),(?,?)" {
+		t.Fatal(f.queries)
+	}
+}
+
+type CopyHooked struct {
+	Id    int
+	Name  string
+	After bool `-`
+}
+
+func (h *CopyHooked) BeforeInsert(ctx context.Context, xr Executor) error {
+	h.Name = "before"
+	return nil
+}
+
+func (h *CopyHooked) AfterInsert(ctx context.Context, xr Executor) error {
+	_, h.After = xr.(*Tx)
+	return nil
+}
+
+func TestCopyFromHook(t *testing.T) {
+	db, f := newFakeDB("postgres", nil)
+	a := []CopyHooked{{Id: 1}}
+	if _, err := db.CopyFrom(a); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.args) != 4 || f.args[1][1] != "before" || !a[0].After {
+		t.Fatal(f.args, a)
+	}
+
+	if _, err := db.CopyFrom([1]CopyHooked{}); !errors.Is(err, ErrInvalidData) {
+		t.Fatal(err)
+	}
+	if _, err := db.Insert(CopyHooked{}); !errors.Is(err, ErrInvalidData) {
+		t.Fatal(err)
+	}
+}
+
+type SoftTag struct {
+	Id        int
+	DeletedAt *time.Time `,soft_delete`
+}
+
+type SoftPost struct {
+	Id   int
+	Tags []*SoftTag `scrud:"SoftPostTag|PostId|TagId,many_to_many"`
+}
+
+func TestManyToManyHas(t *testing.T) {
+	db, f := newFakeDB("mysql", func(q string, args []driver.Value) *fakeResult {
+		return &fakeResult{cols: []string{"n"}, rows: [][]driver.Value{{int64(1)}}}
+	})
+	if ok, err := db.ManyToMany("Tags", &SoftPost{Id: 1}).Has(&SoftTag{Id: 2}); err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+	if len(f.queries) != 1 || f.queries[0] != "SELECT COUNT(*) FROM `SoftPostTag` WHERE (`PostId`=?) AND (`TagId`=?)"+
+		" AND (EXISTS (SELECT `Id` FROM `SoftTag` WHERE (`Id`=?) AND (`DeletedAt` IS NULL))) LIMIT 1" {
+		t.Fatal(f.queries)
+	}
+}
+
+type Versioned struct {
+	Id      int
+	Name    string
+	Version int `,version`
+}
+
+func TestVersion(t *testing.T) {
+	var n int64
+	db, f := newFakeDB("postgres", func(q string, args []driver.Value) *fakeResult {
+		return &fakeResult{n: n}
+	})
+	a := &Versioned{Id: 1, Name: "a", Version: 3}
+	if err := db.Update(a); !errors.Is(err, ErrStaleObject) || a.Version != 3 {
+		t.Fatal(err, a.Version)
+	}
+	if len(f.queries) != 1 || f.queries[0] != `UPDATE "Versioned" SET "Version"="Version"+1,"Name"=$1 WHERE ("Id"=$2) AND ("Version"=$3)` ||
+		len(f.args[0]) != 3 || f.args[0][2] != int64(3) {
+		t.Fatal(f.queries, f.args)
+	}
+	n = 1
+	if err := db.Update(a); err != nil || a.Version != 4 {
+		t.Fatal(err, a.Version)
+	}
+	if _, err := db.Upsert(a); !errors.Is(err, ErrInvalidData) {
+		t.Fatal(err)
+	}
+}
+
+func TestInvalidData(t *testing.T) {
+	db, _ := newFakeDB("mysql", func(q string, args []driver.Value) *fakeResult {
+		return &fakeResult{cols: []string{"Id", "Id"}, rows: [][]driver.Value{{int64(1), int64(1)}}}
+	})
+	if err := db.Update(&SoftPost{Id: 1}, "Tags"); !errors.Is(err, ErrInvalidData) {
+		t.Fatal(err)
+	}
+	if err := db.Fetch(Select("Id", "Id").From("SoftTag")).One(new(SoftTag)); !errors.Is(err, ErrInvalidData) {
+		t.Fatal(err)
+	}
+	if _, err := db.Upsert(&Copied{Name: "a"}); !errors.Is(err, ErrInvalidData) {
+		t.Fatal(err)
+	}
+
+	x, err := table.TableOf(reflect.TypeOf(Copied{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := x.Columns[0].SetValue(reflect.ValueOf(Copied{}), 1); !errors.Is(err, ErrInvalidData) {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectWith(t *testing.T) {
+	db, f := newFakeDB("mysql", nil)
+	if err := db.SelectWith(&Node{Id: 5}, "Parent"); err != ErrNoRows {
+		t.Fatal(err)
+	}
+	if len(f.queries) != 1 || !strings.Contains(f.queries[0], " FROM `ScrudNode` LEFT JOIN `ScrudNode` AS `ScrudNode_Parent`"+
+		" ON `ScrudNode_Parent`.`Id`=`ScrudNode`.`ParentId` WHERE `ScrudNode`.`Id`=?") {
+		t.Fatal(f.queries)
+	}
+}
+
+type KeyedPost struct {
+	Id   int
+	Tags []*KeyedTag `scrud:",many_to_many"`
+}
+
+type KeyedTag struct {
+	Id     int
+	Groups []KeyedGroup `scrud:",many_to_many"`
+}
+
+// through table keyed by the one relation fields with payload
+type KeyedPostTag struct {
+	Post  *KeyedPost `PostId,many_to_one,primary_key`
+	Tag   *KeyedTag  `TagId,many_to_one,primary_key`
+	Order int
+}
+
+func (_ *KeyedPost) ThroughTable(field string) (interface{}, string, string) {
+	return new(KeyedPostTag), "Post", "Tag"
+}
+
+// composite primary key with relations both sides
+type KeyedGroup struct {
+	TenantId int           `,primary_key`
+	Id       int           `,primary_key`
+	Children []*KeyedChild `scrud:",one_to_many"`
+	Tags     []KeyedTag    `scrud:",many_to_many"`
+}
+
+type KeyedChild struct {
+	Id    int
+	Group *KeyedGroup `,many_to_one`
+}
+
+func TestCompositeRelation(t *testing.T) {
+	db, f := newFakeDB("mysql", func(q string, args []driver.Value) *fakeResult {
+		return &fakeResult{cols: []string{"n"}, rows: [][]driver.Value{{int64(1)}}}
+	})
+	m2m := db.ManyToMany("Tags", &KeyedPost{Id: 1})
+	if err := m2m.Add(&KeyedTag{Id: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := m2m.Has(&KeyedTag{Id: 2}); err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+	if err := db.Update(&KeyedPostTag{&KeyedPost{Id: 1}, &KeyedTag{Id: 2}, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := db.Snapshot().Insert(&KeyedPostTag{&KeyedPost{Id: 1}, &KeyedTag{Id: 2}, 3}); err != nil {
+		t.Fatal(err)
+	}
+	for k, q := range []string{
+		"INSERT INTO `KeyedPostTag` (`PostId`,`TagId`) VALUES (?,?)",
+		"SELECT COUNT(*) FROM `KeyedPostTag` WHERE (`PostId`=?) AND (`TagId`=?) LIMIT 1",
+		"UPDATE `KeyedPostTag` SET `Order`=? WHERE (`PostId`=?) AND (`TagId`=?)",
+		"INSERT INTO `SnapshotKeyedPostTag` (`SnapshotTime`,`PostId`,`TagId`,`Order`) VALUES (?,?,?,?)",
+	} {
+		if k >= len(f.queries) || f.queries[k] != q {
+			t.Fatal(f.queries)
+		}
+	}
+
+	if err := Register(KeyedGroup{}); err != nil {
+		t.Fatal(err)
+	}
+	db, f = newFakeDB("mysql", func(q string, args []driver.Value) *fakeResult {
+		switch {
+		case strings.HasPrefix(q, "SELECT `TenantId`,`Id` FROM `KeyedGroup`"):
+			return &fakeResult{cols: []string{"TenantId", "Id"}, rows: [][]driver.Value{{int64(1), int64(2)}}}
+		case strings.HasPrefix(q, "SELECT `Id`,`KeyedGroupTenantId`,`KeyedGroupId` FROM `KeyedChild`"):
+			return &fakeResult{cols: []string{"Id", "KeyedGroupTenantId", "KeyedGroupId"}, rows: [][]driver.Value{{int64(3), int64(1), int64(2)}}}
+		case strings.HasPrefix(q, "SELECT `KeyedGroupTenantId`,`KeyedGroupId`,`KeyedTagId`"):
+			return &fakeResult{cols: []string{"KeyedGroupTenantId", "KeyedGroupId", "KeyedTagId"}, rows: [][]driver.Value{{int64(1), int64(2), int64(4)}}}
+		case strings.HasPrefix(q, "SELECT `Id` FROM `KeyedTag`"):
+			return &fakeResult{cols: []string{"Id"}, rows: [][]driver.Value{{int64(4)}}}
+		}
+		return &fakeResult{}
+	})
+	children := []*KeyedChild{{Id: 3, Group: &KeyedGroup{TenantId: 1, Id: 2}}}
+	if err := db.Preload(&children, "Group"); err != nil {
+		t.Fatal(err)
+	} else if g := children[0].Group; g.TenantId != 1 || g.Id != 2 {
+		t.Fatal(g)
+	}
+	g := &KeyedGroup{TenantId: 1, Id: 2}
+	if err := db.Preload(g, "Children", "Tags"); err != nil {
+		t.Fatal(err)
+	} else if len(g.Children) != 1 || g.Children[0].Id != 3 || len(g.Tags) != 1 || g.Tags[0].Id != 4 {
+		t.Fatal(g)
+	}
+	if err := db.SelectRelation("Groups", &KeyedTag{Id: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SelectWith(&KeyedChild{Id: 3}, "Group"); err != ErrNoRows {
+		t.Fatal(err)
+	}
+	if err := db.ManyToMany("Tags", g).Add(KeyedTag{Id: 4}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ManyToMany("Groups", &KeyedTag{Id: 4}).Remove(g, KeyedGroup{TenantId: 1, Id: 3}); err != nil {
+		t.Fatal(err)
+	}
+	for k, q := range []string{
+		"SELECT `TenantId`,`Id` FROM `KeyedGroup` WHERE (`TenantId`=?) AND (`Id`=?)",
+		"SELECT `Id`,`KeyedGroupTenantId`,`KeyedGroupId` FROM `KeyedChild` WHERE (`KeyedGroupTenantId`=?) AND (`KeyedGroupId`=?)",
+		"SELECT `KeyedGroupTenantId`,`KeyedGroupId`,`KeyedTagId` FROM `KeyedGroupKeyedTag` WHERE (`KeyedGroupTenantId`=?) AND (`KeyedGroupId`=?)",
+		"SELECT `Id` FROM `KeyedTag` WHERE `Id` IN (?)",
+		"SELECT `TenantId`,`Id` FROM `KeyedGroup` WHERE EXISTS (SELECT `KeyedGroupTenantId` FROM `KeyedGroupKeyedTag` WHERE (`KeyedTagId`=?)" +
+			" AND (`KeyedGroupKeyedTag`.`KeyedGroupTenantId`=`KeyedGroup`.`TenantId`) AND (`KeyedGroupKeyedTag`.`KeyedGroupId`=`KeyedGroup`.`Id`))",
+		"SELECT `KeyedChild`.`Id`,`KeyedChild`.`KeyedGroupTenantId`,`KeyedChild`.`KeyedGroupId`,`KeyedChild_Group`.`TenantId` AS `Group.TenantId`," +
+			"`KeyedChild_Group`.`Id` AS `Group.Id` FROM `KeyedChild` LEFT JOIN `KeyedGroup` AS `KeyedChild_Group`" +
+			" ON (`KeyedChild_Group`.`TenantId`=`KeyedChild`.`KeyedGroupTenantId`) AND (`KeyedChild_Group`.`Id`=`KeyedChild`.`KeyedGroupId`)" +
+			" WHERE `KeyedChild`.`Id`=?",
+		"INSERT INTO `KeyedGroupKeyedTag` (`KeyedGroupTenantId`,`KeyedGroupId`,`KeyedTagId`) VALUES (?,?,?)",
+		"DELETE FROM `KeyedGroupKeyedTag` WHERE (`KeyedTagId`=?) AND (((`KeyedGroupTenantId`=?) AND (`KeyedGroupId`=?)) OR ((`KeyedGroupTenantId`=?) AND (`KeyedGroupId`=?)))",
+	} {
+		if k >= len(f.queries) || f.queries[k] != q {
+			t.Fatal(k, f.queries)
+		}
+	}
+}
+
+func TestSelectForUpdate(t *testing.T) {
+	for driverName, want := range map[string]string{
+		"mysql":    "SELECT `Name` FROM `Copied` WHERE `Id`=? FOR UPDATE",
+		"postgres": `SELECT "Name" FROM "Copied" WHERE "Id"=$1 FOR UPDATE`,
+		"sqlite":   `SELECT "Name" FROM "Copied" WHERE "Id"=?`,
+	} {
+		db, f := newFakeDB(driverName, func(q string, args []driver.Value) *fakeResult {
+			return &fakeResult{cols: []string{"Name"}, rows: [][]driver.Value{{"a"}}}
+		})
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := &Copied{Id: 1}
+		if err := tx.SelectForUpdate(a); err != nil || a.Name != "a" {
+			t.Fatal(driverName, err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if len(f.queries) != 3 || f.queries[1] != want {
+			t.Fatal(driverName, f.queries)
+		}
+	}
+}
+
+type UpdateHooked struct {
+	Id   int
+	Name string
+}
+
+func (h *UpdateHooked) BeforeUpdate(ctx context.Context, xr Executor) error {
+	h.Name = "before"
+	return nil
+}
+
+func TestHookValue(t *testing.T) {
+	db, f := newFakeDB("postgres", nil)
+	if err := db.Update(UpdateHooked{Id: 1}); !errors.Is(err, ErrInvalidData) || len(f.queries) != 0 {
+		t.Fatal(err)
+	}
+	a := &UpdateHooked{Id: 1}
+	if err := db.Update(a); err != nil || a.Name != "before" || f.args[0][0] != "before" {
+		t.Fatal(err, f.args)
+	}
+
+	// upsert call no hooks
+	b := &CopyHooked{Id: 1, Name: "a"}
+	if _, err := db.Upsert(b); err != nil || b.Name != "a" || b.After {
+		t.Fatal(err, b)
+	}
+}
@@ -1,7 +1,7 @@
 package scrud
 
 import (
-	"errors"
+	"context"
 	"reflect"
 	"time"
 
@@ -24,13 +24,17 @@ type Snapshot struct {
 }
 
 func (s *Snapshot) Insert(data interface{}) (int64, time.Time, error) {
+	return s.InsertContext(context.Background(), data)
+}
+
+func (s *Snapshot) InsertContext(ctx context.Context, data interface{}) (int64, time.Time, error) {
 	var zt time.Time
 
 	v := reflect.ValueOf(data)
 	t := v.Type()
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
-			return 0, zt, errors.New("scrud: snapshot insert nil")
+			return 0, zt, newError(ErrNilData, "scrud: snapshot insert nil")
 		}
 		v = v.Elem()
 		t = v.Type()
@@ -62,19 +66,15 @@ func (s *Snapshot) Insert(data interface{}) (int64, time.Time, error) {
 	}
 	i.Values(values...)
 
-	if ss := s.xr.Starter(); ss.DriverName() == "postgres" {
+	if s.xr.Starter().DriverName() == "postgres" {
 		var ai int64
-		if q, a, err := i.Expand(ss); err == nil {
-			err = s.xr.QueryRow(
-				q+" RETURNING "+ss.FormatName(idName), a...).Scan(&ai)
-		}
-		if err != nil {
+		if err := fetch(ctx, s.xr, i.Returning(idName)).Row(&ai); err != nil {
 			return 0, zt, err
 		}
 		return ai, st, nil
 	}
 
-	r, err := s.xr.Run(i)
+	r, err := run(ctx, s.xr, i)
 	if err != nil {
 		return 0, zt, err
 	}
@@ -88,13 +88,17 @@ func (s *Snapshot) Insert(data interface{}) (int64, time.Time, error) {
 }
 
 func (s *Snapshot) Select(id int64, data interface{}, columns ...string) (time.Time, error) {
+	return s.SelectContext(context.Background(), id, data, columns...)
+}
+
+func (s *Snapshot) SelectContext(ctx context.Context, id int64, data interface{}, columns ...string) (time.Time, error) {
 	var zt time.Time
 
 	v := reflect.ValueOf(data)
 	t := v.Type()
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
-			return zt, errors.New("scrud: snapshot select nil")
+			return zt, newError(ErrNilData, "scrud: snapshot select nil")
 		}
 		v = v.Elem()
 		t = v.Type()
@@ -134,7 +138,7 @@ func (s *Snapshot) Select(id int64, data interface{}, columns ...string) (time.T
 		scans = append(scans, c.Scan(v))
 	}
 
-	err = s.xr.Fetch(Select(elect...).From(tableName).Where(Eq(idName, id))).Row(scans...)
+	err = fetch(ctx, s.xr, Select(elect...).From(tableName).Where(Eq(idName, id))).Row(scans...)
 	if err != nil {
 		return zt, err
 	}
@@ -149,11 +153,15 @@ func (s *Snapshot) Select(id int64, data interface{}, columns ...string) (time.T
 }
 
 func (s *Snapshot) Delete(id int64, data interface{}) error {
+	return s.DeleteContext(context.Background(), id, data)
+}
+
+func (s *Snapshot) DeleteContext(ctx context.Context, id int64, data interface{}) error {
 	v := reflect.ValueOf(data)
 	t := v.Type()
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
-			return errors.New("scrud: snapshot delete nil")
+			return newError(ErrNilData, "scrud: snapshot delete nil")
 		}
 		v = v.Elem()
 		t = v.Type()
@@ -166,6 +174,6 @@ func (s *Snapshot) Delete(id int64, data interface{}) error {
 
 	tableName, idName, _ := format.SnapshotName(x.Type.Name(), x.Name)
 
-	_, err = s.xr.Run(Delete(tableName).Where(Eq(idName, id)).Limit(1))
+	_, err = run(ctx, s.xr, Delete(tableName).Where(Eq(idName, id)).Limit(1))
 	return err
 }